@@ -0,0 +1,113 @@
+/*
+ Copyright 2020 The GoPlus Authors (goplus.org)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package xtypes
+
+import (
+	"reflect"
+	"unsafe"
+
+	xcall "github.com/goplus/xtypes/internal/reflect"
+)
+
+// Clone returns a deep copy of v. It copies what pointers, slices, maps and
+// interfaces refer to, and arrays and structs, unexported fields included.
+// References to the same value in v refer to the same copy, so aliasing
+// and cycles are kept. Chans, funcs and unsafe pointers are shared.
+func Clone(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	c := &cloner{visited: make(map[visit]reflect.Value)}
+	src := reflect.ValueOf(v)
+	dst := reflect.New(src.Type()).Elem()
+	c.clone(dst, src)
+	return dst.Interface()
+}
+
+type cloner struct {
+	visited map[visit]reflect.Value // (ptr, type) => copy
+}
+
+func (c *cloner) clone(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		key := visit{unsafe.Pointer(src.Pointer()), 0, src.Type()}
+		if p, ok := c.visited[key]; ok {
+			dst.Set(p)
+			return
+		}
+		p := reflect.New(src.Type().Elem())
+		c.visited[key] = p
+		dst.Set(p)
+		c.clone(p.Elem(), src.Elem())
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		key := visit{unsafe.Pointer(src.Pointer()), src.Len(), src.Type()}
+		if s, ok := c.visited[key]; ok {
+			dst.Set(s)
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Cap())
+		c.visited[key] = s
+		dst.Set(s)
+		for i := 0; i < src.Len(); i++ {
+			c.clone(s.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		key := visit{unsafe.Pointer(src.Pointer()), 0, src.Type()}
+		if m, ok := c.visited[key]; ok {
+			dst.Set(m)
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.visited[key] = m
+		dst.Set(m)
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(src.Type().Key()).Elem()
+			c.clone(k, iter.Key())
+			e := reflect.New(src.Type().Elem()).Elem()
+			c.clone(e, iter.Value())
+			m.SetMapIndex(k, e)
+		}
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			c.clone(dst.Index(i), src.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			c.clone(xcall.Field(dst, i), xcall.Field(src, i))
+		}
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		e := reflect.New(src.Elem().Type()).Elem()
+		c.clone(e, src.Elem())
+		dst.Set(e)
+	default:
+		dst.Set(src)
+	}
+}
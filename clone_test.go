@@ -0,0 +1,83 @@
+package xtypes_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/goplus/xtypes"
+)
+
+func TestClone(t *testing.T) {
+	pkg, err := makePkg(migrateTestOld)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("Node").Type(), xtypes.NewContext(nil, nil, nil))
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	setField := func(v reflect.Value, i int, x interface{}) {
+		if err := xtypes.SetField(v.Interface(), i, x); err != nil {
+			t.Fatalf("SetField error %v", err)
+		}
+	}
+	root := reflect.New(rt)
+	kid := reflect.New(rt)
+	setField(root, 0, "root")
+	setField(root, 1, 1)
+	setField(root, 3, root.Interface())
+	setField(kid, 3, root.Interface())
+	kids := reflect.MakeSlice(rt.Field(4).Type, 1, 1)
+	kids.Index(0).Set(kid)
+	setField(root, 4, kids.Interface())
+	attrs := reflect.MakeMap(rt.Field(5).Type)
+	attrs.SetMapIndex(reflect.ValueOf("kid"), kid)
+	setField(root, 5, attrs.Interface())
+
+	c := reflect.ValueOf(xtypes.Clone(root.Interface()))
+	if c.Pointer() == root.Pointer() {
+		t.Fatal("Clone must copy pointers")
+	}
+	if !xtypes.DeepEqual(c.Interface(), root.Interface()) {
+		t.Error("Clone must be deeply equal")
+	}
+	field := func(v reflect.Value, i int) reflect.Value {
+		f, err := xtypes.Field(v.Interface(), i)
+		if err != nil {
+			t.Fatalf("Field error %v", err)
+		}
+		return reflect.ValueOf(f)
+	}
+	if field(c, 3).Pointer() != c.Pointer() {
+		t.Error("cycle not kept")
+	}
+	ckid := field(c, 4).Index(0)
+	if ckid.Pointer() == kid.Pointer() || field(ckid, 3).Pointer() != c.Pointer() {
+		t.Error("slice element not cloned")
+	}
+	if field(c, 5).MapIndex(reflect.ValueOf("kid")).Pointer() != ckid.Pointer() {
+		t.Error("aliasing not kept")
+	}
+
+	type state struct {
+		ch  chan int
+		fn  func()
+		any interface{}
+		arr [1]*int
+	}
+	n := 1
+	s := state{make(chan int), func() {}, []int{1}, [1]*int{&n}}
+	cs := xtypes.Clone(s).(state)
+	if cs.ch != s.ch || reflect.ValueOf(cs.fn).Pointer() != reflect.ValueOf(s.fn).Pointer() {
+		t.Error("chans and funcs must be shared")
+	}
+	if cs.arr[0] == s.arr[0] || *cs.arr[0] != 1 {
+		t.Error("array element not cloned")
+	}
+	if a := cs.any.([]int); &a[0] == &s.any.([]int)[0] {
+		t.Error("interface value not cloned")
+	}
+	if xtypes.Clone(nil) != nil {
+		t.Error("Clone(nil) must be nil")
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ Copyright 2020 The GoPlus Authors (goplus.org)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package xtypes
+
+import (
+	"reflect"
+
+	xcall "github.com/goplus/xtypes/internal/reflect"
+)
+
+// DeepEqualOption configures DeepEqual.
+type DeepEqualOption func(cfg *deepEqualConfig)
+
+type deepEqualConfig struct {
+	xcall.DeepEqualConfig
+	diff *string
+}
+
+// NaNEqual makes DeepEqual treat NaNs as equal to each other.
+func NaNEqual() DeepEqualOption {
+	return func(cfg *deepEqualConfig) {
+		cfg.NaNEqual = true
+	}
+}
+
+// IgnoreUnexported makes DeepEqual skip unexported struct fields.
+func IgnoreUnexported() DeepEqualOption {
+	return func(cfg *deepEqualConfig) {
+		cfg.IgnoreUnexported = true
+	}
+}
+
+// Comparer makes DeepEqual compare the values of typ with fn. The values
+// fn gets are usable even when they come from unexported fields.
+func Comparer(typ reflect.Type, fn func(x, y reflect.Value) bool) DeepEqualOption {
+	return func(cfg *deepEqualConfig) {
+		if cfg.Comparers == nil {
+			cfg.Comparers = make(map[reflect.Type]func(x, y reflect.Value) bool)
+		}
+		cfg.Comparers[typ] = fn
+	}
+}
+
+// ReportDiff makes DeepEqual store in path the path of the first
+// difference it finds, like .f[1]["k"], relative to the compared values.
+func ReportDiff(path *string) DeepEqualOption {
+	return func(cfg *deepEqualConfig) {
+		cfg.diff = path
+	}
+}
+
+// DeepEqual reports whether x and y are deeply equal, like
+// reflect.DeepEqual, for values of types made by ToType as well as host
+// types. Unexported fields are compared too, unless IgnoreUnexported is set.
+func DeepEqual(x, y interface{}, opts ...DeepEqualOption) bool {
+	var cfg deepEqualConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	eq, diff := xcall.DeepEqualWith(x, y, &cfg.DeepEqualConfig)
+	if cfg.diff != nil {
+		*cfg.diff = diff
+	}
+	return eq
+}
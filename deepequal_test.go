@@ -0,0 +1,64 @@
+package xtypes_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/goplus/xtypes"
+)
+
+func TestDeepEqual(t *testing.T) {
+	pkg, err := makePkg(accessorSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("Inner").Type(), xtypes.NewContext(nil, nil, nil))
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	newInner := func(c []int, m map[string]int) interface{} {
+		v := reflect.New(rt).Interface()
+		if err := xtypes.SetField(v, 0, c); err != nil {
+			t.Fatalf("SetField error %v", err)
+		}
+		if err := xtypes.SetField(v, 1, m); err != nil {
+			t.Fatalf("SetField error %v", err)
+		}
+		return v
+	}
+	x := newInner([]int{1, 2}, map[string]int{"a": 1})
+	if !xtypes.DeepEqual(x, newInner([]int{1, 2}, map[string]int{"a": 1})) {
+		t.Error("DeepEqual must be true")
+	}
+	var diff string
+	if xtypes.DeepEqual(x, newInner([]int{1, 3}, map[string]int{"a": 1}), xtypes.ReportDiff(&diff)) || diff != ".c[1]" {
+		t.Errorf("DeepEqual must be false at .c[1]: %v", diff)
+	}
+	if xtypes.DeepEqual(x, newInner([]int{1, 2}, map[string]int{"a": 2}), xtypes.ReportDiff(&diff)) || diff != `.m["a"]` {
+		t.Errorf(`DeepEqual must be false at .m["a"]: %v`, diff)
+	}
+	if !xtypes.DeepEqual(x, newInner(nil, nil), xtypes.IgnoreUnexported()) {
+		t.Error("DeepEqual must ignore unexported fields")
+	}
+
+	type point struct {
+		X, Y float64
+	}
+	nan := point{math.NaN(), 1}
+	if xtypes.DeepEqual(nan, nan) {
+		t.Error("NaN must not be equal by default")
+	}
+	if !xtypes.DeepEqual(nan, nan, xtypes.NaNEqual()) {
+		t.Error("NaN must be equal with NaNEqual")
+	}
+	approx := xtypes.Comparer(reflect.TypeOf(0.0), func(x, y reflect.Value) bool {
+		return math.Abs(x.Float()-y.Float()) < 0.01
+	})
+	if !xtypes.DeepEqual(point{1, 2}, point{1.001, 2}, approx) {
+		t.Error("DeepEqual must use the comparer")
+	}
+	if xtypes.DeepEqual(point{1, 2}, point{1, 3}, approx, xtypes.ReportDiff(&diff)) || diff != ".Y" {
+		t.Errorf("DeepEqual must be false at .Y: %v", diff)
+	}
+}
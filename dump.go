@@ -0,0 +1,334 @@
+/*
+ Copyright 2020 The GoPlus Authors (goplus.org)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package xtypes
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	xcall "github.com/goplus/xtypes/internal/reflect"
+)
+
+// DumpOption configures Dump.
+type DumpOption func(d *dumper)
+
+// MaxDepth makes Dump elide the values nested deeper than depth.
+func MaxDepth(depth int) DumpOption {
+	return func(d *dumper) {
+		d.maxDepth = depth
+	}
+}
+
+// CallStringers makes Dump print the String method result of the values
+// that implement fmt.Stringer. A panic of String is printed too.
+func CallStringers() DumpOption {
+	return func(d *dumper) {
+		d.stringers = true
+	}
+}
+
+// Dump prints v to w in Go syntax, with the type names qualified by their
+// package and unexported fields included. It works for values of types made
+// by ToType as well as host types. A pointer, map or slice referred to more
+// than once is labeled, like #1=&T{...}, and later references to it,
+// cycles included, are printed as back-references, like #1. Map keys are
+// sorted. Unlike fmt, Dump does not call String methods unless
+// CallStringers is set.
+func Dump(w io.Writer, v interface{}, opts ...DumpOption) error {
+	d := &dumper{
+		w:      w,
+		refs:   make(map[visit]int),
+		labels: make(map[visit]int),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	x := reflect.ValueOf(v)
+	d.count(x)
+	d.dump(x, 0)
+	d.print("\n")
+	return d.err
+}
+
+type dumper struct {
+	w         io.Writer
+	err       error
+	maxDepth  int
+	stringers bool
+	refs      map[visit]int // reference => number of references
+	labels    map[visit]int // reference => label, once printed
+}
+
+func (d *dumper) print(s string) {
+	if d.err == nil {
+		_, d.err = io.WriteString(d.w, s)
+	}
+}
+
+// reference returns the key of v, a pointer, a map or a slice, and
+// whether v is a non-nil reference.
+func reference(v reflect.Value) (visit, bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map:
+		if v.IsNil() {
+			return visit{}, false
+		}
+		return visit{unsafe.Pointer(v.Pointer()), 0, v.Type()}, true
+	case reflect.Slice:
+		if v.IsNil() || v.Len() == 0 {
+			return visit{}, false
+		}
+		return visit{unsafe.Pointer(v.Pointer()), v.Len(), v.Type()}, true
+	}
+	return visit{}, false
+}
+
+// count counts the references to the pointers, maps and slices of v.
+func (d *dumper) count(v reflect.Value) {
+	if key, ok := reference(v); ok {
+		d.refs[key]++
+		if d.refs[key] > 1 {
+			return
+		}
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			d.count(v.Elem())
+		}
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			d.count(v.Index(i))
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			d.count(iter.Key())
+			d.count(iter.Value())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			d.count(xcall.Field(v, i))
+		}
+	}
+}
+
+func (d *dumper) indent(depth int) {
+	d.print("\n" + strings.Repeat("\t", depth))
+}
+
+func (d *dumper) dump(v reflect.Value, depth int) {
+	if !v.IsValid() {
+		d.print("nil")
+		return
+	}
+	if key, ok := reference(v); ok && d.refs[key] > 1 {
+		if label, ok := d.labels[key]; ok {
+			d.print("#" + strconv.Itoa(label))
+			return
+		}
+		label := len(d.labels) + 1
+		d.labels[key] = label
+		d.print("#" + strconv.Itoa(label) + "=")
+	}
+	if d.stringers && d.dumpStringer(v) {
+		return
+	}
+	t := v.Type()
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			d.print("nil")
+			return
+		}
+		d.dump(v.Elem(), depth)
+	case reflect.Ptr:
+		if v.IsNil() {
+			d.print("(" + t.String() + ")(nil)")
+			return
+		}
+		d.print("&")
+		d.dump(v.Elem(), depth)
+	case reflect.Struct:
+		d.print(t.String() + "{")
+		if v.NumField() == 0 {
+			d.print("}")
+			return
+		}
+		if d.maxDepth > 0 && depth >= d.maxDepth {
+			d.print("...}")
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			d.indent(depth + 1)
+			d.print(t.Field(i).Name + ": ")
+			d.dump(xcall.Field(v, i), depth+1)
+			d.print(",")
+		}
+		d.indent(depth)
+		d.print("}")
+	case reflect.Array, reflect.Slice:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			d.print(t.String() + "(nil)")
+			return
+		}
+		d.print(t.String() + "{")
+		if v.Len() == 0 {
+			d.print("}")
+			return
+		}
+		if d.maxDepth > 0 && depth >= d.maxDepth {
+			d.print("...}")
+			return
+		}
+		if isBasic(t.Elem().Kind()) {
+			for i := 0; i < v.Len(); i++ {
+				if i > 0 {
+					d.print(", ")
+				}
+				d.dump(v.Index(i), depth+1)
+			}
+			d.print("}")
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			d.indent(depth + 1)
+			d.dump(v.Index(i), depth+1)
+			d.print(",")
+		}
+		d.indent(depth)
+		d.print("}")
+	case reflect.Map:
+		if v.IsNil() {
+			d.print(t.String() + "(nil)")
+			return
+		}
+		d.print(t.String() + "{")
+		if v.Len() == 0 {
+			d.print("}")
+			return
+		}
+		if d.maxDepth > 0 && depth >= d.maxDepth {
+			d.print("...}")
+			return
+		}
+		keys := v.MapKeys()
+		sortValues(keys)
+		for _, k := range keys {
+			d.indent(depth + 1)
+			d.dump(k, depth+1)
+			d.print(": ")
+			d.dump(v.MapIndex(k), depth+1)
+			d.print(",")
+		}
+		d.indent(depth)
+		d.print("}")
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		if v.IsNil() {
+			d.print("(" + t.String() + ")(nil)")
+			return
+		}
+		d.print(fmt.Sprintf("(%v)(%#x)", t, v.Pointer()))
+	default:
+		s := basicString(v)
+		if t.Name() != t.Kind().String() {
+			s = t.String() + "(" + s + ")"
+		}
+		d.print(s)
+	}
+}
+
+// dumpStringer prints the String method result of v, if v is a non-nil
+// fmt.Stringer.
+func (d *dumper) dumpStringer(v reflect.Value) (ok bool) {
+	if v.Kind() == reflect.Interface || !v.Type().Implements(tyStringer) {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		if v.IsNil() {
+			return false
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			d.print(fmt.Sprintf("%v(<String panic: %v>)", v.Type(), r))
+			ok = true
+		}
+	}()
+	s := v.Interface().(fmt.Stringer).String()
+	d.print(v.Type().String() + "(" + strconv.Quote(s) + ")")
+	return true
+}
+
+var tyStringer = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+func isBasic(kind reflect.Kind) bool {
+	return kind >= reflect.Bool && kind <= reflect.Complex128 || kind == reflect.String
+}
+
+func basicString(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	case reflect.Complex64, reflect.Complex128:
+		return strconv.FormatComplex(v.Complex(), 'g', -1, v.Type().Bits())
+	case reflect.String:
+		return strconv.Quote(v.String())
+	}
+	return v.Type().String()
+}
+
+// sortValues sorts map keys, by value for basic kinds and by their Go
+// syntax otherwise.
+func sortValues(keys []reflect.Value) {
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch a.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return a.Uint() < b.Uint()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		case reflect.String:
+			return a.String() < b.String()
+		}
+		return sprintValue(a) < sprintValue(b)
+	})
+}
+
+// sprintValue returns the Go syntax of v, cycles printed as
+// back-references.
+func sprintValue(v reflect.Value) string {
+	var b strings.Builder
+	d := &dumper{w: &b, refs: make(map[visit]int), labels: make(map[visit]int)}
+	d.count(v)
+	d.dump(v, 0)
+	return b.String()
+}
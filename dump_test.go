@@ -0,0 +1,110 @@
+package xtypes_test
+
+import (
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goplus/xtypes"
+)
+
+var dumpSrc = `
+package main
+
+type Node struct {
+	Name  string
+	id    int
+	next  *Node
+	attrs map[string]int
+}
+
+func (n Node) String() string {
+	return n.Name
+}
+`
+
+func TestDump(t *testing.T) {
+	pkg, err := makePkg(dumpSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		return func(args []reflect.Value) []reflect.Value {
+			panic("boom")
+		}
+	}, nil, nil)
+	typ, err := xtypes.ToType(pkg.Scope().Lookup("Node").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	root := reflect.New(typ)
+	root.Elem().Field(0).SetString("root")
+	xtypes.SetField(root.Interface(), 1, 1)
+	xtypes.SetField(root.Interface(), 2, root.Interface())
+	xtypes.SetField(root.Interface(), 3, reflect.ValueOf(map[string]int{"b": 2, "a": 1}).Convert(typ.Field(3).Type).Interface())
+
+	dump := func(opts ...xtypes.DumpOption) string {
+		var b strings.Builder
+		if err := xtypes.Dump(&b, root.Interface(), opts...); err != nil {
+			t.Fatalf("Dump error %v", err)
+		}
+		return b.String()
+	}
+	want := `#1=&main.Node{
+	Name: "root",
+	id: 1,
+	next: #1,
+	attrs: map[string]int{
+		"a": 1,
+		"b": 2,
+	},
+}
+`
+	if s := dump(); s != want {
+		t.Errorf("Dump:\n%s\nwant:\n%s", s, want)
+	}
+	want = `#1=&main.Node{
+	Name: "root",
+	id: 1,
+	next: #1,
+	attrs: map[string]int{...},
+}
+`
+	if s := dump(xtypes.MaxDepth(1)); s != want {
+		t.Errorf("Dump MaxDepth:\n%s\nwant:\n%s", s, want)
+	}
+	want = "#1=*main.Node(<String panic: boom>)\n"
+	if s := dump(xtypes.CallStringers()); s != want {
+		t.Errorf("Dump CallStringers:\n%s\nwant:\n%s", s, want)
+	}
+
+	other := reflect.New(typ)
+	other.Elem().Field(0).SetString("other")
+	xtypes.SetField(other.Interface(), 2, other.Interface())
+	m := reflect.MakeMap(reflect.MapOf(root.Type(), reflect.TypeOf(0)))
+	m.SetMapIndex(root, reflect.ValueOf(1))
+	m.SetMapIndex(other, reflect.ValueOf(2))
+	var b strings.Builder
+	if err := xtypes.Dump(&b, m.Interface(), xtypes.MaxDepth(2)); err != nil {
+		t.Fatalf("Dump error %v", err)
+	}
+	want = `map[*main.Node]int{
+	#1=&main.Node{
+		Name: "other",
+		id: 0,
+		next: #1,
+		attrs: map[string]int(nil),
+	}: 2,
+	#2=&main.Node{
+		Name: "root",
+		id: 1,
+		next: #2,
+		attrs: map[string]int{...},
+	}: 1,
+}
+`
+	if s := b.String(); s != want {
+		t.Errorf("Dump cyclic map keys:\n%s\nwant:\n%s", s, want)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ Copyright 2020 The GoPlus Authors (goplus.org)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package xtypes
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"reflect"
+
+	xcall "github.com/goplus/xtypes/internal/reflect"
+)
+
+// Equal reports whether a == b, following the rules of the == operator of
+// Go. a and b must have the same type, or one of them must be an interface
+// the type of the other implements; two interfaces compare their dynamic
+// values. Unlike reflect, it returns an error for values that are not
+// comparable, including interfaces holding values of the same uncomparable
+// type.
+// Arrays and structs compare element-wise, unexported fields included.
+func Equal(a, b reflect.Value) (bool, error) {
+	at, bt := a.Type(), b.Type()
+	if at != bt {
+		switch {
+		case at.Kind() == reflect.Interface && bt.Kind() == reflect.Interface &&
+			(bt.AssignableTo(at) || at.AssignableTo(bt)):
+			return equal(a, b)
+		case at.Kind() == reflect.Interface && bt.AssignableTo(at):
+			return equalInterface(a, b)
+		case bt.Kind() == reflect.Interface && at.AssignableTo(bt):
+			return equalInterface(b, a)
+		}
+		return false, fmt.Errorf("mismatched types %v and %v", at, bt)
+	}
+	return equal(a, b)
+}
+
+// equalInterface reports whether the interface a holds b.
+func equalInterface(a, b reflect.Value) (bool, error) {
+	if !b.Type().Comparable() {
+		return false, uncomparable(b.Type())
+	}
+	if a.IsNil() || a.Elem().Type() != b.Type() {
+		return false, nil
+	}
+	return equal(a.Elem(), b)
+}
+
+// equal reports whether a == b, a and b having the same type or both being
+// interfaces.
+func equal(a, b reflect.Value) (bool, error) {
+	if !a.Type().Comparable() {
+		return false, uncomparable(a.Type())
+	}
+	switch a.Kind() {
+	case reflect.Bool:
+		return a.Bool() == b.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float(), nil
+	case reflect.Complex64, reflect.Complex128:
+		return a.Complex() == b.Complex(), nil
+	case reflect.String:
+		return a.String() == b.String(), nil
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return a.Pointer() == b.Pointer(), nil
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil(), nil
+		}
+		a, b = a.Elem(), b.Elem()
+		if a.Type() != b.Type() {
+			return false, nil
+		}
+		return equal(a, b)
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if eq, err := equal(a.Index(i), b.Index(i)); !eq || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case reflect.Struct:
+		t := a.Type()
+		for i := 0; i < a.NumField(); i++ {
+			if t.Field(i).Name == "_" {
+				continue
+			}
+			if eq, err := equal(xcall.Field(a, i), xcall.Field(b, i)); !eq || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return false, uncomparable(a.Type())
+}
+
+func uncomparable(t reflect.Type) error {
+	return fmt.Errorf("comparing uncomparable type %v", t)
+}
+
+var hashSeed = maphash.MakeSeed()
+
+// Hash returns a hash of v consistent with Equal: values that are Equal
+// have the same hash, an interface and the value it holds included. It
+// returns an error for values that are not comparable.
+func Hash(v reflect.Value) (uint64, error) {
+	var h maphash.Hash
+	h.SetSeed(hashSeed)
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		// Equal compares an interface with a value of another type by
+		// the value it holds.
+		v = v.Elem()
+	}
+	if err := hashValue(&h, v); err != nil {
+		return 0, err
+	}
+	return h.Sum64(), nil
+}
+
+func hashValue(h *maphash.Hash, v reflect.Value) error {
+	if !v.Type().Comparable() {
+		return uncomparable(v.Type())
+	}
+	var buf [8]byte
+	writeUint := func(x uint64) {
+		binary.LittleEndian.PutUint64(buf[:], x)
+		h.Write(buf[:])
+	}
+	writeFloat := func(f float64) {
+		if f == 0 {
+			f = 0 // -0 == +0
+		}
+		writeUint(math.Float64bits(f))
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			h.WriteByte(1)
+		} else {
+			h.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeFloat(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		writeFloat(real(c))
+		writeFloat(imag(c))
+	case reflect.String:
+		h.WriteString(v.String())
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		writeUint(uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			writeUint(0)
+			return nil
+		}
+		v = v.Elem()
+		writeUint(uint64(reflect.ValueOf(v.Type()).Pointer()))
+		return hashValue(h, v)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := hashValue(h, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).Name == "_" {
+				continue
+			}
+			if err := hashValue(h, xcall.Field(v, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package xtypes_test
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/goplus/xtypes"
+)
+
+func TestEqual(t *testing.T) {
+	type key struct {
+		name string
+		_    int
+		v    interface{}
+	}
+	var e1, e2 interface{} = 1, int64(1)
+	buf := new(bytes.Buffer)
+	var r io.Reader = buf
+	var e3, e4 interface{} = buf, []int{1}
+	var nilErr error
+	tests := []struct {
+		a, b interface{}
+		eq   bool
+		err  bool
+	}{
+		{1, 1, true, false},
+		{1, 2, false, false},
+		{math.NaN(), math.NaN(), false, false},
+		{0.0, math.Copysign(0, -1), true, false},
+		{"a", "a", true, false},
+		{[2]int{1, 2}, [2]int{1, 2}, true, false},
+		{key{name: "a", v: 1}, key{name: "a", v: 1}, true, false},
+		{key{name: "a", v: 1}, key{name: "b", v: 1}, false, false},
+		{key{name: "a", v: 1}, key{name: "a", v: int64(1)}, false, false},
+		{key{name: "a", v: []int{}}, key{name: "a", v: []int{}}, false, true},
+		{key{name: "a", v: []int{}}, key{name: "b", v: []int{}}, false, false},
+		{[]int{}, []int{}, false, true},
+		{1, "a", false, true},
+		{&e1, &e1, true, false},
+		{&e1, &e2, false, false},
+		{&e1, 1, true, false},
+		{&e2, int64(1), true, false},
+		{&r, &e3, true, false},
+		{&e3, &r, true, false},
+		{&e4, &e1, false, false},
+	}
+	for _, test := range tests {
+		a, b := reflect.ValueOf(test.a), reflect.ValueOf(test.b)
+		if a.Kind() == reflect.Ptr && a.Elem().Kind() == reflect.Interface {
+			a = a.Elem()
+		}
+		if b.Kind() == reflect.Ptr && b.Elem().Kind() == reflect.Interface {
+			b = b.Elem()
+		}
+		eq, err := xtypes.Equal(a, b)
+		if eq != test.eq || (err != nil) != test.err {
+			t.Errorf("Equal(%v, %v) = %v, %v", test.a, test.b, eq, err)
+			continue
+		}
+		if eq {
+			ha, _ := xtypes.Hash(a)
+			hb, _ := xtypes.Hash(b)
+			if ha != hb {
+				t.Errorf("Hash(%v) != Hash(%v)", a, b)
+			}
+		}
+	}
+	any := reflect.ValueOf(&e1).Elem()
+	if eq, err := xtypes.Equal(any, reflect.ValueOf(1)); !eq || err != nil {
+		t.Errorf("Equal(interface, int) = %v, %v", eq, err)
+	}
+	if eq, err := xtypes.Equal(reflect.ValueOf(&nilErr).Elem(), reflect.ValueOf((*myError)(nil))); eq || err != nil {
+		t.Errorf("Equal(nil error, (*myError)(nil)) = %v, %v", eq, err)
+	}
+	if _, err := xtypes.Hash(reflect.ValueOf(key{v: map[int]int{}})); err == nil {
+		t.Error("Hash must fail for an uncomparable value")
+	}
+
+	pkg, err := makePkg(methodSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("T").Type(), xtypes.NewContext(nil, nil, nil))
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	a, b := reflect.New(rt), reflect.New(rt)
+	if eq, err := xtypes.Equal(a.Elem(), b.Elem()); !eq || err != nil {
+		t.Errorf("Equal of zero values = %v, %v", eq, err)
+	}
+	if err := xtypes.SetField(a.Interface(), 1, "a"); err != nil {
+		t.Fatalf("SetField error %v", err)
+	}
+	if eq, err := xtypes.Equal(a.Elem(), b.Elem()); eq || err != nil {
+		t.Errorf("Equal with an unexported field set = %v, %v", eq, err)
+	}
+}
+
+type myError struct{}
+
+func (*myError) Error() string {
+	return "error"
+}
@@ -0,0 +1,68 @@
+/*
+ Copyright 2020 The GoPlus Authors (goplus.org)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package xtypes
+
+import (
+	"reflect"
+)
+
+// Invoker calls a method, with the receiver as first argument.
+type Invoker func(args []reflect.Value) []reflect.Value
+
+// Interceptor wraps the calls of methods installed by ToType, promoted
+// methods included. It carries on a call by calling next, possibly with
+// other arguments, or returns the results itself.
+type Interceptor func(info *MethodInfo, args []reflect.Value, next Invoker) []reflect.Value
+
+// Intercepter is the optional interface of a Context that intercepts the
+// calls of the methods it installs. The Context made by NewContext
+// implements it.
+type Intercepter interface {
+	Intercept(fn Interceptor)
+}
+
+// Intercept adds fn to the interceptors of the Context. Interceptors apply
+// to methods already installed too, which are installed again, so
+// Intercept must not run while methods of converted types are called; the
+// first added is the outermost one.
+func (t *context) Intercept(fn Interceptor) {
+	t.interceptors = append(t.interceptors[:len(t.interceptors):len(t.interceptors)], fn)
+	t.installMethods()
+}
+
+// interceptMethod returns fn, the implementation of the method described by
+// info, wrapped to go through the interceptors of ctx. Without interceptors,
+// fn is installed as it is.
+func interceptMethod(ctx Context, info *MethodInfo, fn Invoker) Invoker {
+	t, ok := baseContext(ctx)
+	if !ok || len(t.interceptors) == 0 {
+		return fn
+	}
+	list := t.interceptors
+	return func(args []reflect.Value) []reflect.Value {
+		return intercept(list, info, args, fn)
+	}
+}
+
+func intercept(list []Interceptor, info *MethodInfo, args []reflect.Value, fn Invoker) []reflect.Value {
+	if len(list) == 0 {
+		return fn(args)
+	}
+	return list[0](info, args, func(args []reflect.Value) []reflect.Value {
+		return intercept(list[1:], info, args, fn)
+	})
+}
@@ -0,0 +1,52 @@
+package xtypes_test
+
+import (
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goplus/xtypes"
+)
+
+func TestIntercept(t *testing.T) {
+	pkg, err := makePkg(methodSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		if method.Name() == "Size" {
+			return func(args []reflect.Value) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(int(args[0].Field(0).Int()))}
+			}
+		}
+		return nil
+	}, nil, nil)
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("T").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	var calls []string
+	intercepter := ctx.(xtypes.Intercepter)
+	intercepter.Intercept(func(info *xtypes.MethodInfo, args []reflect.Value, next xtypes.Invoker) []reflect.Value {
+		calls = append(calls, info.Recv.String()+"."+info.Func.Name())
+		return next(args)
+	})
+	intercepter.Intercept(func(info *xtypes.MethodInfo, args []reflect.Value, next xtypes.Invoker) []reflect.Value {
+		r := next(args)
+		if info.Promoted() {
+			r[0] = reflect.ValueOf(int(r[0].Int() + 1))
+		}
+		return r
+	})
+	v := reflect.New(rt).Elem()
+	if r := v.MethodByName("Size").Call(nil); r[0].Int() != 1 {
+		t.Errorf("call promoted Size: %v", r[0])
+	}
+	if r := v.Field(0).MethodByName("Size").Call(nil); r[0].Int() != 0 {
+		t.Errorf("call Size: %v", r[0])
+	}
+	if s := strings.Join(calls, ","); s != "main.T.Size,main.N.Size,main.N.Size" {
+		t.Errorf("intercepted calls %v", s)
+	}
+}
@@ -6,7 +6,13 @@
 
 package reflect
 
-import "unsafe"
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unsafe"
+)
 
 // During deepValueEqual, must keep track of checks that are
 // in progress. The comparison algorithm assumes that all
@@ -18,16 +24,39 @@ type visit struct {
 	typ Type
 }
 
+// DeepEqualConfig configures DeepEqualWith.
+type DeepEqualConfig struct {
+	NaNEqual         bool // NaNs are equal to each other
+	IgnoreUnexported bool // unexported struct fields are not compared
+	Comparers        map[reflect.Type]func(x, y reflect.Value) bool
+}
+
+type deepEqual struct {
+	*DeepEqualConfig
+	visited map[visit]bool
+	diff    []string // path of the first difference, innermost step first
+}
+
+// fail records step in the path of the first difference.
+func (d *deepEqual) fail(step string) bool {
+	d.diff = append(d.diff, step)
+	return false
+}
+
 // Tests for deep equality using reflected types. The map argument tracks
 // comparisons that have already been seen, which allows short circuiting on
 // recursive types.
-func deepValueEqual(v1, v2 Value, visited map[visit]bool) bool {
+func (d *deepEqual) deepValueEqual(v1, v2 Value) bool {
+	visited := d.visited
 	if !v1.IsValid() || !v2.IsValid() {
 		return v1.IsValid() == v2.IsValid()
 	}
 	if v1.Type() != v2.Type() {
 		return false
 	}
+	if fn, ok := d.Comparers[toRType(v1.typ)]; ok {
+		return fn(toValue(v1), toValue(v2))
+	}
 
 	// We want to avoid putting more in the visited map than we need to.
 	// For any possible reference cycle that might be encountered,
@@ -87,8 +116,8 @@ func deepValueEqual(v1, v2 Value, visited map[visit]bool) bool {
 	switch v1.Kind() {
 	case Array:
 		for i := 0; i < v1.Len(); i++ {
-			if !deepValueEqual(v1.Index(i), v2.Index(i), visited) {
-				return false
+			if !d.deepValueEqual(v1.Index(i), v2.Index(i)) {
+				return d.fail(fmt.Sprintf("[%d]", i))
 			}
 		}
 		return true
@@ -103,8 +132,8 @@ func deepValueEqual(v1, v2 Value, visited map[visit]bool) bool {
 			return true
 		}
 		for i := 0; i < v1.Len(); i++ {
-			if !deepValueEqual(v1.Index(i), v2.Index(i), visited) {
-				return false
+			if !d.deepValueEqual(v1.Index(i), v2.Index(i)) {
+				return d.fail(fmt.Sprintf("[%d]", i))
 			}
 		}
 		return true
@@ -112,16 +141,21 @@ func deepValueEqual(v1, v2 Value, visited map[visit]bool) bool {
 		if v1.IsNil() || v2.IsNil() {
 			return v1.IsNil() == v2.IsNil()
 		}
-		return deepValueEqual(v1.Elem(), v2.Elem(), visited)
+		return d.deepValueEqual(v1.Elem(), v2.Elem())
 	case Ptr:
 		if v1.Pointer() == v2.Pointer() {
 			return true
 		}
-		return deepValueEqual(v1.Elem(), v2.Elem(), visited)
+		return d.deepValueEqual(v1.Elem(), v2.Elem())
 	case Struct:
+		tt := (*structType)(unsafe.Pointer(v1.typ))
 		for i, n := 0, v1.NumField(); i < n; i++ {
-			if !deepValueEqual(v1.Field(i), v2.Field(i), visited) {
-				return false
+			name := tt.fields[i].name
+			if d.IgnoreUnexported && !name.isExported() {
+				continue
+			}
+			if !d.deepValueEqual(v1.Field(i), v2.Field(i)) {
+				return d.fail("." + name.name())
 			}
 		}
 		return true
@@ -138,8 +172,8 @@ func deepValueEqual(v1, v2 Value, visited map[visit]bool) bool {
 		for _, k := range v1.MapKeys() {
 			val1 := v1.MapIndex(k)
 			val2 := v2.MapIndex(k)
-			if !val1.IsValid() || !val2.IsValid() || !deepValueEqual(val1, val2, visited) {
-				return false
+			if !val1.IsValid() || !val2.IsValid() || !d.deepValueEqual(val1, val2) {
+				return d.fail(fmt.Sprintf("[%#v]", valueInterface(k, false)))
 			}
 		}
 		return true
@@ -149,12 +183,26 @@ func deepValueEqual(v1, v2 Value, visited map[visit]bool) bool {
 		}
 		// Can't do better than this:
 		return false
+	case Float32, Float64:
+		if d.NaNEqual && math.IsNaN(v1.Float()) && math.IsNaN(v2.Float()) {
+			return true
+		}
+		return v1.Float() == v2.Float()
+	case Complex64, Complex128:
+		if d.NaNEqual && isNaN(v1.Complex()) && isNaN(v2.Complex()) {
+			return true
+		}
+		return v1.Complex() == v2.Complex()
 	default:
 		// Normal equality suffices
 		return valueInterface(v1, false) == valueInterface(v2, false)
 	}
 }
 
+func isNaN(c complex128) bool {
+	return math.IsNaN(real(c)) || math.IsNaN(imag(c))
+}
+
 // DeepEqual reports whether x and y are ``deeply equal,'' defined as follows.
 // Two values of identical type are deeply equal if one of the following cases applies.
 // Values of distinct types are never deeply equal.
@@ -215,5 +263,28 @@ func DeepEqual(x, y interface{}) bool {
 	if v1.Type() != v2.Type() {
 		return false
 	}
-	return deepValueEqual(v1, v2, make(map[visit]bool))
+	d := &deepEqual{DeepEqualConfig: &DeepEqualConfig{}, visited: make(map[visit]bool)}
+	return d.deepValueEqual(v1, v2)
+}
+
+// DeepEqualWith is DeepEqual configured by cfg. If x and y differ, it also
+// returns the path of their first difference, like .f[1]["k"], relative to
+// x and y.
+func DeepEqualWith(x, y interface{}, cfg *DeepEqualConfig) (bool, string) {
+	if x == nil || y == nil {
+		return x == y, ""
+	}
+	v1 := ValueOf(x)
+	v2 := ValueOf(y)
+	if v1.Type() != v2.Type() {
+		return false, ""
+	}
+	d := &deepEqual{DeepEqualConfig: cfg, visited: make(map[visit]bool)}
+	if d.deepValueEqual(v1, v2) {
+		return true, ""
+	}
+	for i, j := 0, len(d.diff)-1; i < j; i, j = i+1, j-1 {
+		d.diff[i], d.diff[j] = d.diff[j], d.diff[i]
+	}
+	return false, strings.Join(d.diff, "")
 }
@@ -0,0 +1,50 @@
+package reflect
+
+import (
+	"reflect"
+	"sync/atomic"
+	"unsafe"
+)
+
+// methodInfo mirrors the method description of github.com/goplus/reflectx
+// v0.6.0. Its method stubs, that interface calls and reflect calls go
+// through, call Func with the receiver and the arguments.
+type methodInfo struct {
+	Func     reflect.Value
+	Type     reflect.Type
+	inTyp    reflect.Type
+	outTyp   reflect.Type
+	name     string
+	index    int
+	isz      uintptr
+	osz      uintptr
+	pointer  bool
+	variadic bool
+	onePtr   bool
+}
+
+//go:linkname typInfoMap github.com/goplus/reflectx.typInfoMap
+var typInfoMap map[reflect.Type][]*methodInfo
+
+// SetMethodFunc makes fn the func the method name of typ and *typ calls,
+// typ being a type whose methods are set by reflectx.SetMethodSet. fn
+// takes the receiver as first argument, like the func reflectx.MakeMethod
+// wraps, but is called as it is: a typed func skips the []reflect.Value
+// wrapper. It reports false if typ has no such method. The method
+// description is replaced as a whole, so that a concurrent call of the
+// method calls either the old func or fn.
+func SetMethodFunc(typ reflect.Type, name string, fn reflect.Value) bool {
+	var ok bool
+	for _, t := range []reflect.Type{typ, reflect.PtrTo(typ)} {
+		infos := typInfoMap[t]
+		for i, info := range infos {
+			if info.name == name {
+				m := *info
+				m.Func = fn
+				atomic.StorePointer((*unsafe.Pointer)(unsafe.Pointer(&infos[i])), unsafe.Pointer(&m))
+				ok = true
+			}
+		}
+	}
+	return ok
+}
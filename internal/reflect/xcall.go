@@ -13,29 +13,123 @@ func toValue(v Value) reflect.Value {
 	return *(*reflect.Value)(unsafe.Pointer(&v))
 }
 
-func MethodByName(v reflect.Value, name string) Value {
-	return fromValue(v).MethodByName(name)
+// fromValues and toValues convert slices in place, Value and reflect.Value
+// have the same layout.
+func fromValues(v []reflect.Value) []Value {
+	return *(*[]Value)(unsafe.Pointer(&v))
 }
 
-func Call(v Value, args []reflect.Value) []reflect.Value {
-	var res []Value
-	n := len(args)
-	if n == 0 {
-		res = v.Call(nil)
-	} else {
-		a := make([]Value, n, n)
-		for i := 0; i < n; i++ {
-			a[i] = fromValue(args[i])
+func toValues(v []Value) []reflect.Value {
+	return *(*[]reflect.Value)(unsafe.Pointer(&v))
+}
+
+func fromType(t reflect.Type) *rtype {
+	return (*rtype)((*[2]unsafe.Pointer)(unsafe.Pointer(&t))[1])
+}
+
+// toRType is the inverse of fromType, every reflect.Type being a
+// *reflect.rtype.
+func toRType(t *rtype) reflect.Type {
+	typ := reflect.TypeOf(0)
+	(*[2]unsafe.Pointer)(unsafe.Pointer(&typ))[1] = unsafe.Pointer(t)
+	return typ
+}
+
+// MethodIndex returns the index of the method name of typ. Unlike reflect,
+// methods are indexed among all methods, unexported ones included. An
+// unexported name must be declared in pkgPath, if pkgPath is not empty.
+func MethodIndex(typ reflect.Type, pkgPath, name string) (int, bool) {
+	t := fromType(typ)
+	if t.Kind() == Interface {
+		tt := (*interfaceType)(unsafe.Pointer(t))
+		for i := range tt.methods {
+			if matchName(tt.nameOff(tt.methods[i].name), pkgPath, name, tt.pkgPath.name) {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	ut := t.uncommon()
+	if ut == nil {
+		return 0, false
+	}
+	for i, p := range ut.methods() {
+		if matchName(t.nameOff(p.name), pkgPath, name, func() string {
+			return t.nameOff(ut.pkgPath).name()
+		}) {
+			return i, true
 		}
-		res = v.Call(a)
 	}
-	nout := len(res)
-	if nout == 0 {
+	return 0, false
+}
+
+// matchName reports whether n is name, declared in pkgPath if unexported.
+// typPkgPath returns the package of a name without one.
+func matchName(n name, pkgPath, name string, typPkgPath func() string) bool {
+	if n.name() != name {
+		return false
+	}
+	if pkgPath == "" || n.isExported() {
+		return true
+	}
+	path := n.pkgPath()
+	if path == "" {
+		path = typPkgPath()
+	}
+	return path == pkgPath
+}
+
+// MethodType returns the type, without receiver, of the i'th method of typ,
+// as indexed by MethodIndex. It returns nil if the linker dropped the
+// method.
+func MethodType(typ reflect.Type, i int) reflect.Type {
+	keepMethods(i)
+	t := fromType(typ)
+	if t.Kind() == Interface {
+		tt := (*interfaceType)(unsafe.Pointer(t))
+		return toRType(tt.typeOff(tt.methods[i].typ))
+	}
+	p := t.exportedMethods()[i]
+	if p.mtyp == -1 || p.ifn == -1 || p.tfn == -1 {
 		return nil
 	}
-	r := make([]reflect.Value, nout, nout)
-	for i := 0; i < nout; i++ {
-		r[i] = toValue(res[i])
+	return toRType(t.typeOff(p.mtyp))
+}
+
+// keepMethods makes reflect.Value.Method reachable, with an index the
+// compiler cannot resolve. Unless it is, the linker drops the types and
+// funcs of the methods this package reads, as it does for programs that
+// do not call methods through reflect. It never calls Method: i is a
+// method index, never negative.
+func keepMethods(i int) {
+	if i < 0 {
+		reflect.Value{}.Method(i)
 	}
-	return r
+}
+
+// MethodValue returns the i'th method of v, as indexed by MethodIndex.
+func MethodValue(v reflect.Value, i int) Value {
+	return fromValue(v).Method(i)
+}
+
+func Call(v Value, args []reflect.Value) []reflect.Value {
+	return toValues(v.Call(fromValues(args)))
+}
+
+func CallSlice(v Value, args []reflect.Value) []reflect.Value {
+	return toValues(v.CallSlice(fromValues(args)))
+}
+
+// Field is like reflect.Value.Field, but the field is not read-only
+// when it is unexported.
+func Field(v reflect.Value, i int) reflect.Value {
+	return toValue(fromValue(v).Field(i))
+}
+
+// Unrestricted returns v without the read-only flag reflect sets on
+// values obtained through unexported fields.
+func Unrestricted(v reflect.Value) reflect.Value {
+	x := fromValue(v)
+	x.flag &^= flagRO
+	return toValue(x)
 }
@@ -0,0 +1,589 @@
+/*
+ Copyright 2020 The GoPlus Authors (goplus.org)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package xtypes
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	xcall "github.com/goplus/xtypes/internal/reflect"
+)
+
+// MethodInfo describes a method installed by ToType.
+type MethodInfo struct {
+	Func      *types.Func      // declared method
+	Type      reflect.Type     // method type, without receiver
+	Recv      reflect.Type     // converted receiver type, a pointer if Pointer is set
+	Pointer   bool             // whether the method is only in the pointer method set
+	Selection *types.Selection // the method in the method set of the converted type
+	Index     []int            // Selection.Index()
+	Embedded  []*types.Var     // embedded fields a promoted method is reached through
+	Source    MethodSource     // where the implementation comes from
+
+	fn    func(args []reflect.Value) []reflect.Value // installed implementation
+	value reflect.Value                              // typed implementation, called without boxing
+}
+
+// MethodSource tells where the implementation of an installed method comes
+// from.
+type MethodSource int
+
+const (
+	// SourceNone is a method without implementation, that panics. With
+	// WithLazyMethods, a method is only known to have none after its
+	// first call, and its source stays SourceFindMethod or
+	// SourceBindMethod.
+	SourceNone MethodSource = iota
+	// SourceFindMethod is a method found by Context.FindMethod. A method
+	// FindMethod has no implementation for is in UnimplementedMethods.
+	SourceFindMethod
+	// SourceBindMethod is a method bound by MethodBinder.BindMethod. A
+	// method BindMethod has no implementation for is in
+	// UnimplementedMethods.
+	SourceBindMethod
+	// SourceMethodValue is a typed func found by
+	// MethodValueFinder.FindMethodValue. It is called directly until it
+	// is rebound.
+	SourceMethodValue
+	// SourcePromoted is a method promoted through the embedded fields.
+	SourcePromoted
+	// SourceInterface is a method of an interface type, implemented by
+	// the dynamic value.
+	SourceInterface
+)
+
+var methodSources = [...]string{
+	SourceNone:        "none",
+	SourceFindMethod:  "FindMethod",
+	SourceBindMethod:  "BindMethod",
+	SourceMethodValue: "FindMethodValue",
+	SourcePromoted:    "promoted",
+	SourceInterface:   "interface",
+}
+
+func (s MethodSource) String() string {
+	if s >= 0 && int(s) < len(methodSources) {
+		return methodSources[s]
+	}
+	return fmt.Sprintf("MethodSource(%d)", int(s))
+}
+
+// Promoted reports whether the method is promoted from an embedded field.
+func (info *MethodInfo) Promoted() bool {
+	return len(info.Index) > 1
+}
+
+// MethodBinder is the optional interface of a Context that binds methods
+// from a MethodInfo. When the Context implements it, ToType calls
+// BindMethod instead of FindMethod, promoted methods included. A nil
+// result for a promoted method installs the default promotion through the
+// embedded field. Other methods are bound like the methods FindMethod
+// finds, when the Context is or unwraps to the one made by NewContext: once,
+// lazily with WithLazyMethods, and they can be rebound.
+type MethodBinder interface {
+	BindMethod(info *MethodInfo) func(args []reflect.Value) []reflect.Value
+}
+
+// MethodValueFinder is the optional interface of a Context that implements
+// methods with typed funcs, like a host func(T, int) int for a method
+// func (T) M(int) int. The func takes the receiver as first argument; the
+// receiver and the arguments must be assignable to its parameters, and its
+// results must have the method result types. Interface and reflect calls
+// of the method call the func directly, without the []reflect.Value
+// boxing of the funcs FindMethod returns. The method goes through the
+// boxing when the Context has interceptors, or once it is rebound. An
+// invalid Value falls back to FindMethod.
+type MethodValueFinder interface {
+	FindMethodValue(info *MethodInfo) reflect.Value
+}
+
+// findMethodValue returns the typed func of info, if any, as a boxed method
+// implementation, and sets info.value to the func while it can be called
+// directly.
+func findMethodValue(info *MethodInfo, ctx Context) (func(args []reflect.Value) []reflect.Value, error) {
+	finder, ok := ctx.(MethodValueFinder)
+	if !ok || info.Promoted() {
+		return nil, nil
+	}
+	fn := finder.FindMethodValue(info)
+	if !fn.IsValid() {
+		return nil, nil
+	}
+	if err := checkMethodValue(fn.Type(), info); err != nil {
+		return nil, fmt.Errorf("method `%s` - %w", methodName(info.Func), err)
+	}
+	info.Source = SourceMethodValue
+	call := fn.Call
+	if info.Type.IsVariadic() {
+		call = fn.CallSlice
+	}
+	base, ok := baseContext(ctx)
+	if !ok {
+		info.value = fn
+		return call, nil
+	}
+	slot, ok := base.slots[info.Func]
+	if !ok {
+		slot = base.methodSlot(info.Func, func() func(args []reflect.Value) []reflect.Value {
+			return call
+		})
+		slot.direct = true
+	}
+	if slot.direct && len(base.interceptors) == 0 {
+		info.value = fn
+		typ := info.Recv
+		if info.Pointer {
+			typ = typ.Elem()
+		}
+		name, ftyp := info.Func.Name(), methodExprType(info.Recv, info.Type)
+		slot.undirect = func() {
+			xcall.SetMethodFunc(typ, name, reflect.MakeFunc(ftyp, slot.call))
+		}
+	}
+	return slot.call, nil
+}
+
+func checkMethodValue(ftyp reflect.Type, info *MethodInfo) error {
+	mtyp := info.Type
+	if ftyp.Kind() != reflect.Func || ftyp.NumIn() != mtyp.NumIn()+1 || ftyp.NumOut() != mtyp.NumOut() ||
+		ftyp.IsVariadic() != mtyp.IsVariadic() || !info.Recv.AssignableTo(ftyp.In(0)) {
+		return fmt.Errorf("bad func type %v", ftyp)
+	}
+	for i := 0; i < mtyp.NumIn(); i++ {
+		if !mtyp.In(i).AssignableTo(ftyp.In(i + 1)) {
+			return fmt.Errorf("bad func type %v, cannot use %v as parameter %v", ftyp, mtyp.In(i), i+1)
+		}
+	}
+	for i := 0; i < mtyp.NumOut(); i++ {
+		if ftyp.Out(i) != mtyp.Out(i) {
+			return fmt.Errorf("bad func type %v, result %v must be %v", ftyp, i, mtyp.Out(i))
+		}
+	}
+	return nil
+}
+
+func bindMethod(info *MethodInfo, ctx Context) func(args []reflect.Value) []reflect.Value {
+	var mfn func(args []reflect.Value) []reflect.Value
+	binder, ok := ctx.(MethodBinder)
+	switch {
+	case info.Promoted():
+		if ok {
+			mfn = binder.BindMethod(info)
+			info.Source = SourceBindMethod
+		}
+		if mfn == nil {
+			mfn = promotedMethod(info)
+			info.Source = SourcePromoted
+		}
+		return mfn
+	case ok:
+		info.Source = SourceBindMethod
+		if base, ok := baseContext(ctx); ok {
+			mfn = base.methodSlot(info.Func, func() func(args []reflect.Value) []reflect.Value {
+				return binder.BindMethod(info)
+			}).call
+		} else {
+			mfn = binder.BindMethod(info)
+		}
+	default:
+		info.Source = SourceFindMethod
+		mfn = ctx.FindMethod(info.Type, info.Func)
+	}
+	if mfn == nil {
+		mfn = notImplemented(info.Func, nil)
+		info.Source = SourceNone
+	} else if base, ok := baseContext(ctx); ok && base.missing(info.Func) {
+		info.Source = SourceNone
+	}
+	return mfn
+}
+
+// promotedTarget is the method a promoted method resolves to: the installed
+// method of an embedded type made by ToType, or else the index of the
+// method of a host type or interface.
+type promotedTarget struct {
+	info  *MethodInfo
+	index int
+}
+
+// promotedMethod calls the method through the embedded field. The method
+// is looked up once, on the first call; the implementation installed for
+// an embedded type made by ToType is then called directly with the field
+// as receiver. Like compiled code, it panics with a nil pointer
+// dereference when a nil embedded pointer or interface is on the way,
+// except for the last pointer of a pointer method, which becomes its nil
+// receiver.
+func promotedMethod(info *MethodInfo) func(args []reflect.Value) []reflect.Value {
+	fn := info.Func
+	idx := info.Index[:len(info.Index)-1]
+	isptr := isPointer(fn.Type().Underlying().(*types.Signature).Recv().Type())
+	variadic := info.Type.IsVariadic()
+	var target atomic.Value // *promotedTarget
+	return func(args []reflect.Value) []reflect.Value {
+		this := args[0]
+		for _, i := range idx {
+			if this.Kind() == reflect.Ptr {
+				if this.IsNil() {
+					nilPointerDereference()
+				}
+				this = this.Elem()
+			}
+			this = this.Field(i)
+		}
+		switch {
+		case isptr:
+			if this.Kind() != reflect.Ptr {
+				this = this.Addr()
+			}
+		case this.Kind() == reflect.Ptr, this.Kind() == reflect.Interface:
+			if this.IsNil() {
+				nilPointerDereference()
+			}
+		}
+		p, _ := target.Load().(*promotedTarget)
+		if p == nil {
+			p = resolvePromoted(this.Type(), fn)
+			target.Store(p)
+		}
+		if p.info != nil {
+			if !p.info.Pointer && this.Kind() == reflect.Ptr {
+				this = this.Elem()
+			}
+			args[0] = this
+			return p.info.fn(args)
+		}
+		return callMethod(this, p.index, args[1:], variadic)
+	}
+}
+
+// resolvePromoted returns the method fn of rt, the type of the embedded
+// field a promoted method is called on.
+func resolvePromoted(rt reflect.Type, fn *types.Func) *promotedTarget {
+	if rt.Kind() != reflect.Interface {
+		if info, ok := lookupMethodInfo(rt, isFunc(fn)); ok && info.fn != nil {
+			return &promotedTarget{info: info}
+		}
+	}
+	i, ok := methodIndex(rt, funcPkgPath(fn), fn.Name())
+	if !ok {
+		panic("xtypes: method " + methodName(fn) + " not found in " + rt.String())
+	}
+	return &promotedTarget{index: i}
+}
+
+// nilPointerDereference panics with the runtime error of a nil pointer
+// dereference.
+func nilPointerDereference() {
+	var p *int
+	_ = *p
+}
+
+// methodInfos maps a type made by ToType to the descriptions of its
+// methods.
+var methodInfos sync.Map // reflect.Type => *methodTable
+
+type methodTable struct {
+	indexed []*MethodInfo // in the order of reflect.Type.Method
+	all     []*MethodInfo // unexported methods included
+	iface   bool          // methods of an interface type, not installed
+}
+
+// setMethodInfos records the methods of typ and *typ, infos describing the
+// method set of *typ.
+func setMethodInfos(typ reflect.Type, infos []*MethodInfo) {
+	for _, rt := range []reflect.Type{typ, reflect.PtrTo(typ)} {
+		table := &methodTable{indexed: make([]*MethodInfo, rt.NumMethod())}
+		for _, info := range infos {
+			if rt == typ && info.Pointer {
+				continue
+			}
+			table.all = append(table.all, info)
+		}
+		for i := range table.indexed {
+			name := rt.Method(i).Name
+			for _, info := range table.all {
+				if info.Func.Name() == name {
+					table.indexed[i] = info
+					break
+				}
+			}
+		}
+		methodInfos.Store(rt, table)
+	}
+}
+
+// setInterfaceMethodInfos records the methods of typ, the interface type
+// converted from t.
+func setInterfaceMethodInfos(t types.Type, typ reflect.Type) {
+	table := &methodTable{indexed: make([]*MethodInfo, typ.NumMethod()), iface: true}
+	methods := IntuitiveMethodSet(t)
+	for i := range table.indexed {
+		m := typ.Method(i)
+		for _, sel := range methods {
+			fn := sel.Obj().(*types.Func)
+			if fn.Name() != m.Name || !fn.Exported() && funcPkgPath(fn) != m.PkgPath {
+				continue
+			}
+			info := &MethodInfo{
+				Func:      fn,
+				Type:      m.Type,
+				Recv:      typ,
+				Selection: sel,
+				Index:     sel.Index(),
+				Source:    SourceInterface,
+			}
+			table.indexed[i] = info
+			table.all = append(table.all, info)
+			break
+		}
+	}
+	methodInfos.Store(typ, table)
+}
+
+// lookupMethodInfo returns the description of the installed method of rt,
+// a type made by ToType, match reports true for.
+func lookupMethodInfo(rt reflect.Type, match func(fn *types.Func) bool) (*MethodInfo, bool) {
+	v, ok := methodInfos.Load(rt)
+	if !ok || v.(*methodTable).iface {
+		return nil, false
+	}
+	for _, info := range v.(*methodTable).all {
+		if match(info.Func) {
+			return info, true
+		}
+	}
+	return nil, false
+}
+
+// MethodInfoOf returns the description of the method of rt at index i, rt
+// being a type made by ToType or a pointer to it. It tells where the method
+// is declared, through which embedded fields it is promoted and where its
+// implementation comes from. The methods of an interface type are
+// SourceInterface.
+func MethodInfoOf(rt reflect.Type, i int) (*MethodInfo, bool) {
+	v, ok := methodInfos.Load(rt)
+	if !ok {
+		return nil, false
+	}
+	list := v.(*methodTable).indexed
+	if i < 0 || i >= len(list) || list[i] == nil {
+		return nil, false
+	}
+	return list[i], true
+}
+
+// MethodIndex returns the index of fn among the methods of rt, a type made
+// by ToType or a pointer to it, so that rt.Method(i) or Value.Method(i) is
+// fn. For a promoted method, fn is the method of the embedded type. It
+// reports false if fn is not in the method set of rt. Identical interface
+// types share their reflect.Type, so for an interface type, fn matches the
+// method of the same name and package of any of them.
+func MethodIndex(rt reflect.Type, fn *types.Func) (int, bool) {
+	v, ok := methodInfos.Load(rt)
+	if !ok {
+		return 0, false
+	}
+	table := v.(*methodTable)
+	for i, info := range table.indexed {
+		if info != nil && (info.Func == fn || table.iface && info.Func.Id() == fn.Id()) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// MethodFunc is the inverse of MethodIndex: it returns the method of rt at
+// index i.
+func MethodFunc(rt reflect.Type, i int) (*types.Func, bool) {
+	info, ok := MethodInfoOf(rt, i)
+	if !ok {
+		return nil, false
+	}
+	return info.Func, true
+}
+
+// embeddedFields returns the embedded fields of t selected by index, the
+// index of a promoted method.
+func embeddedFields(t types.Type, index []int) []*types.Var {
+	var fields []*types.Var
+	for _, i := range index[:len(index)-1] {
+		if ptr, ok := t.Underlying().(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		field := t.Underlying().(*types.Struct).Field(i)
+		fields = append(fields, field)
+		t = field.Type()
+	}
+	return fields
+}
+
+// methodSlot is the indirection between an installed method and its
+// implementation, so that the implementation can be swapped.
+type methodSlot struct {
+	fn       atomic.Value // func(args []reflect.Value) []reflect.Value
+	stub     func(args []reflect.Value) []reflect.Value
+	mu       sync.Mutex
+	resolved bool
+	missing  bool   // no implementation, calls panic
+	direct   bool   // the typed func of the method is called directly
+	undirect func() // makes the method call fn instead of the typed func
+}
+
+// newMethodSlot returns a slot whose implementation is found by resolve,
+// at once or on the first call if lazy is set. stub replaces a nil
+// implementation.
+func newMethodSlot(resolve func() func(args []reflect.Value) []reflect.Value, stub func(args []reflect.Value) []reflect.Value, lazy bool) *methodSlot {
+	slot := &methodSlot{stub: stub}
+	if lazy {
+		slot.fn.Store(func(args []reflect.Value) []reflect.Value {
+			slot.resolve(resolve)
+			return slot.call(args)
+		})
+	} else {
+		slot.resolve(resolve)
+	}
+	return slot
+}
+
+// resolve sets the implementation found by resolve, unless the slot is
+// already resolved. resolve runs without the lock held, so that it may call
+// the method or Rebind it; the first implementation set wins.
+func (p *methodSlot) resolve(resolve func() func(args []reflect.Value) []reflect.Value) {
+	p.mu.Lock()
+	resolved := p.resolved
+	p.mu.Unlock()
+	if resolved {
+		return
+	}
+	fn := resolve()
+	p.mu.Lock()
+	if !p.resolved {
+		p.set(fn)
+	}
+	p.mu.Unlock()
+}
+
+func (p *methodSlot) set(fn func(args []reflect.Value) []reflect.Value) {
+	p.missing = fn == nil
+	if p.missing {
+		fn = p.stub
+	}
+	p.fn.Store(fn)
+	p.resolved = true
+}
+
+func (p *methodSlot) call(args []reflect.Value) []reflect.Value {
+	return p.fn.Load().(func(args []reflect.Value) []reflect.Value)(args)
+}
+
+// Rebinder is the optional interface of a Context that swaps the
+// implementations of installed methods and reports the missing ones. The
+// Context made by NewContext implements it.
+type Rebinder interface {
+	Rebind(method *types.Func, fn func(args []reflect.Value) []reflect.Value) error
+	UnimplementedMethods() []*types.Func
+}
+
+// Rebind replaces the implementation of method, a method installed by a
+// previous ToType. Existing types and values keep working and call fn
+// from now on, including through promoted methods. Rebind can run while
+// the methods are called.
+func (t *context) Rebind(method *types.Func, fn func(args []reflect.Value) []reflect.Value) error {
+	slot, ok := t.slots[method]
+	if !ok {
+		return fmt.Errorf("method `%s` is not installed", methodName(method))
+	}
+	slot.mu.Lock()
+	undirect := slot.undirect
+	slot.direct, slot.undirect = false, nil
+	slot.set(fn)
+	slot.mu.Unlock()
+	if undirect != nil {
+		undirect()
+	}
+	return nil
+}
+
+// missing reports whether method has a slot known to have no
+// implementation.
+func (t *context) missing(method *types.Func) bool {
+	slot, ok := t.slots[method]
+	if !ok {
+		return false
+	}
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	return slot.missing
+}
+
+// UnimplementedMethods returns the installed methods that have no
+// implementation, sorted by name. Calling them panics. With WithLazyMethods,
+// a method is only known to be unimplemented after its first call.
+func (t *context) UnimplementedMethods() []*types.Func {
+	var methods []*types.Func
+	for method, slot := range t.slots {
+		slot.mu.Lock()
+		if slot.missing {
+			methods = append(methods, method)
+		}
+		slot.mu.Unlock()
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		return methodName(methods[i]) < methodName(methods[j])
+	})
+	return methods
+}
+
+// notImplemented returns a method implementation that panics. fset, if not
+// nil, is used to report where method is declared.
+func notImplemented(method *types.Func, fset *token.FileSet) func(args []reflect.Value) []reflect.Value {
+	msg := "xtypes: method " + methodName(method) + " not implemented"
+	if fset != nil && method.Pos().IsValid() {
+		msg += " (declared at " + fset.Position(method.Pos()).String() + ")"
+	}
+	return func(args []reflect.Value) []reflect.Value {
+		panic(msg)
+	}
+}
+
+// funcPkgPath returns the package path of fn, or "" if it has none.
+func funcPkgPath(fn *types.Func) string {
+	if pkg := fn.Pkg(); pkg != nil {
+		return pkg.Path()
+	}
+	return ""
+}
+
+// methodName returns the name of method qualified by its receiver type
+// name, like pkg.T.M.
+func methodName(method *types.Func) string {
+	if recv := method.Type().(*types.Signature).Recv(); recv != nil {
+		typ := recv.Type()
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		if named, ok := typ.(*types.Named); ok && named.Obj().Pkg() != nil {
+			return named.Obj().Pkg().Path() + "." + named.Obj().Name() + "." + method.Name()
+		}
+	}
+	return method.FullName()
+}
@@ -0,0 +1,762 @@
+package xtypes_test
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/goplus/xtypes"
+)
+
+var methodSrc = `
+package main
+
+type N struct {
+	size int
+}
+
+func (n N) Size() int {
+	return n.size
+}
+
+func (n *N) SetSize(size int) {
+	n.size = size
+}
+
+type T struct {
+	N
+	name string
+}
+
+func (t T) Name() string {
+	return t.name
+}
+
+var t1 struct{ *T }
+`
+
+func constMethod(r interface{}) func(args []reflect.Value) []reflect.Value {
+	return func(args []reflect.Value) []reflect.Value {
+		return []reflect.Value{reflect.ValueOf(r)}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	pkg, err := makePkg(methodSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		if method.Name() == "Size" {
+			return constMethod(1)
+		}
+		return nil
+	}, nil, nil)
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("T").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	v := reflect.New(rt).Elem()
+	if r := v.MethodByName("Size").Call(nil); r[0].Int() != 1 {
+		t.Errorf("call Size: %v", r[0])
+	}
+	n := pkg.Scope().Lookup("N").Type().(*types.Named)
+	size := n.Method(0)
+	if err := ctx.(xtypes.Rebinder).Rebind(size, constMethod(2)); err != nil {
+		t.Fatalf("Rebind error %v", err)
+	}
+	if r := v.MethodByName("Size").Call(nil); r[0].Int() != 2 {
+		t.Errorf("call promoted Size after Rebind: %v", r[0])
+	}
+	if r := v.Field(0).MethodByName("Size").Call(nil); r[0].Int() != 2 {
+		t.Errorf("call Size after Rebind: %v", r[0])
+	}
+	name := types.NewFunc(0, pkg, "Name", types.NewSignature(nil, nil, nil, false))
+	if err := ctx.(xtypes.Rebinder).Rebind(name, constMethod("")); err == nil {
+		t.Error("Rebind must fail for a method not installed")
+	}
+}
+
+func TestLazyMethods(t *testing.T) {
+	pkg, err := makePkg(methodSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	var found []string
+	var ctx xtypes.Context
+	var rebound bool
+	ctx = xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		found = append(found, method.Name())
+		switch method.Name() {
+		case "Size":
+			return constMethod(1)
+		case "SetSize":
+			// Rebind while the method is resolved, the Rebind wins
+			if err := ctx.(xtypes.Rebinder).Rebind(method, func(args []reflect.Value) []reflect.Value {
+				rebound = true
+				return nil
+			}); err != nil {
+				t.Errorf("Rebind error %v", err)
+			}
+		}
+		return nil
+	}, nil, nil, xtypes.WithLazyMethods())
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("T").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	if len(found) != 0 {
+		t.Fatalf("methods found before call: %v", found)
+	}
+	v := reflect.New(rt).Elem()
+	for i := 0; i < 2; i++ {
+		if r := v.MethodByName("Size").Call(nil); r[0].Int() != 1 {
+			t.Errorf("call Size: %v", r[0])
+		}
+	}
+	if len(found) != 1 || found[0] != "Size" {
+		t.Errorf("methods found: %v", found)
+	}
+	v.Addr().MethodByName("SetSize").Call([]reflect.Value{reflect.ValueOf(1)})
+	if !rebound {
+		t.Error("SetSize not rebound while resolved")
+	}
+	defer func() {
+		if r := recover(); r != "xtypes: method main.T.Name not implemented" {
+			t.Errorf("bad panic %v", r)
+		}
+	}()
+	v.MethodByName("Name").Call(nil)
+}
+
+func TestUnimplementedMethods(t *testing.T) {
+	fset := token.NewFileSet()
+	pkg, err := makePkgWithFileSet(fset, methodSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		if method.Name() == "Size" {
+			return constMethod(1)
+		}
+		return nil
+	}, nil, nil, xtypes.WithFileSet(fset))
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("T").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	var names []string
+	for _, m := range ctx.(xtypes.Rebinder).UnimplementedMethods() {
+		names = append(names, m.Name())
+	}
+	if s := strings.Join(names, ","); s != "SetSize,Name" {
+		t.Errorf("unimplemented methods %v", s)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != "xtypes: method main.T.Name not implemented (declared at <src>:21:12)" {
+				t.Errorf("bad panic %v", r)
+			}
+		}()
+		reflect.New(rt).Elem().MethodByName("Name").Call(nil)
+	}()
+	name := pkg.Scope().Lookup("T").Type().(*types.Named).Method(0)
+	if err := ctx.(xtypes.Rebinder).Rebind(name, constMethod("T")); err != nil {
+		t.Fatalf("Rebind error %v", err)
+	}
+	if r := reflect.New(rt).Elem().MethodByName("Name").Call(nil); r[0].String() != "T" {
+		t.Errorf("call Name after Rebind: %v", r[0])
+	}
+	if n := len(ctx.(xtypes.Rebinder).UnimplementedMethods()); n != 1 {
+		t.Errorf("unimplemented methods after Rebind: %v", n)
+	}
+}
+
+type binderContext struct {
+	xtypes.Context
+	infos map[string]*xtypes.MethodInfo
+}
+
+func (p *binderContext) Unwrap() xtypes.Context {
+	return p.Context
+}
+
+func (p *binderContext) BindMethod(info *xtypes.MethodInfo) func(args []reflect.Value) []reflect.Value {
+	p.infos[info.Recv.String()+"."+info.Func.Name()] = info
+	switch {
+	case info.Func.Name() == "Name":
+		return constMethod(info.Recv.String())
+	case info.Func.Name() == "Size" && !info.Promoted():
+		return constMethod(2)
+	}
+	return nil
+}
+
+func TestMethodBinder(t *testing.T) {
+	fset := token.NewFileSet()
+	pkg, err := makePkgWithFileSet(fset, methodSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := &binderContext{xtypes.NewContext(nil, nil, nil, xtypes.WithFileSet(fset)), make(map[string]*xtypes.MethodInfo)}
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("T").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	name, setSize := ctx.infos["main.T.Name"], ctx.infos["*main.T.SetSize"]
+	if name == nil || setSize == nil {
+		t.Fatalf("bound methods %v", ctx.infos)
+	}
+	if name.Recv != rt || name.Pointer || name.Promoted() || len(name.Embedded) != 0 {
+		t.Errorf("bad info %+v", name)
+	}
+	if r := reflect.New(rt).Elem().MethodByName("Name").Call(nil); r[0].String() != "main.T" {
+		t.Errorf("call Name: %v", r[0])
+	}
+	if setSize.Recv != reflect.PtrTo(rt) || !setSize.Pointer || !setSize.Promoted() {
+		t.Errorf("bad info %+v", setSize)
+	}
+	if len(setSize.Embedded) != 1 || setSize.Embedded[0].Name() != "N" {
+		t.Errorf("bad embedded fields %v", setSize.Embedded)
+	}
+	if setSize.Selection.Obj() != setSize.Func || len(setSize.Index) != 2 {
+		t.Errorf("bad selection %v", setSize.Selection)
+	}
+	if r := reflect.New(rt).Elem().MethodByName("Size").Call(nil); r[0].Int() != 2 {
+		t.Errorf("call promoted Size: %v", r[0])
+	}
+
+	// N.SetSize is not bound
+	rebinder := ctx.Context.(xtypes.Rebinder)
+	missing := rebinder.UnimplementedMethods()
+	if len(missing) != 1 || missing[0].Name() != "SetSize" {
+		t.Fatalf("unimplemented methods %v", missing)
+	}
+	v := reflect.New(rt)
+	if r := callPanic(func() {
+		v.MethodByName("SetSize").Call([]reflect.Value{reflect.ValueOf(1)})
+	}); r != "xtypes: method main.N.SetSize not implemented (declared at <src>:12:13)" {
+		t.Errorf("bad panic %v", r)
+	}
+	var size int64
+	if err := rebinder.Rebind(missing[0], func(args []reflect.Value) []reflect.Value {
+		size = args[1].Int()
+		return nil
+	}); err != nil {
+		t.Fatalf("Rebind error %v", err)
+	}
+	if v.MethodByName("SetSize").Call([]reflect.Value{reflect.ValueOf(1)}); size != 1 {
+		t.Error("call SetSize after Rebind")
+	}
+}
+
+var methodValueSrc = `
+package main
+
+type T struct {
+	X int
+}
+
+func (t T) Add(n int) int {
+	return t.X + n
+}
+`
+
+type valueContext struct {
+	xtypes.Context
+}
+
+func (p valueContext) Unwrap() xtypes.Context {
+	return p.Context
+}
+
+func (p valueContext) FindMethodValue(info *xtypes.MethodInfo) reflect.Value {
+	return reflect.ValueOf(func(t struct{ X int }, n int) int {
+		return t.X + n
+	})
+}
+
+func makeMethodValueType(tb testing.TB, native bool) (reflect.Type, xtypes.Context) {
+	pkg, err := makePkg(methodValueSrc)
+	if err != nil {
+		tb.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		return func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(int(args[0].Field(0).Int() + args[1].Int()))}
+		}
+	}, nil, nil)
+	var conv xtypes.Context = ctx
+	if native {
+		conv = valueContext{ctx}
+	}
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("T").Type(), conv)
+	if err != nil {
+		tb.Fatalf("ToType error %v", err)
+	}
+	return rt, ctx
+}
+
+func TestMethodValue(t *testing.T) {
+	rt, ctx := makeMethodValueType(t, true)
+	v := reflect.New(rt).Elem()
+	v.Field(0).SetInt(1)
+	if r := v.MethodByName("Add").Call([]reflect.Value{reflect.ValueOf(2)}); r[0].Int() != 3 {
+		t.Errorf("call Add: %v", r[0])
+	}
+	if r := v.Interface().(interface{ Add(int) int }).Add(3); r != 4 {
+		t.Errorf("call Add through interface: %v", r)
+	}
+	info, ok := xtypes.MethodInfoOf(rt, 0)
+	if !ok || info.Source != xtypes.SourceMethodValue {
+		t.Fatalf("bad info %+v", info)
+	}
+	if err := ctx.(xtypes.Rebinder).Rebind(info.Func, constMethod(0)); err != nil {
+		t.Fatalf("Rebind error %v", err)
+	}
+	if r := v.Interface().(interface{ Add(int) int }).Add(3); r != 0 {
+		t.Errorf("call Add after Rebind: %v", r)
+	}
+
+	pkg, err := makePkg(methodValueSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	bad := badValueContext{xtypes.NewContext(nil, nil, nil)}
+	if _, err := xtypes.ToType(pkg.Scope().Lookup("T").Type(), bad); err == nil {
+		t.Error("ToType must fail for a bad method func type")
+	}
+}
+
+type badValueContext struct {
+	xtypes.Context
+}
+
+func (p badValueContext) FindMethodValue(info *xtypes.MethodInfo) reflect.Value {
+	return reflect.ValueOf(func(t struct{ X int }, n int) int64 {
+		return 0
+	})
+}
+
+var methodBenchTypes = make(map[bool]reflect.Type)
+
+func benchmarkMethod(b *testing.B, native bool) {
+	rt, ok := methodBenchTypes[native]
+	if !ok {
+		rt, _ = makeMethodValueType(b, native)
+		methodBenchTypes[native] = rt
+	}
+	v := reflect.New(rt).Elem()
+	add := v.Interface().(interface{ Add(int) int })
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		add.Add(i)
+	}
+}
+
+func BenchmarkMethodFunc(b *testing.B) {
+	benchmarkMethod(b, false)
+}
+
+func BenchmarkMethodValue(b *testing.B) {
+	benchmarkMethod(b, true)
+}
+
+func TestPromotedMethod(t *testing.T) {
+	pkg, err := makePkg(methodSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		switch method.Name() {
+		case "Size":
+			return func(args []reflect.Value) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(int(args[0].Field(0).Int()))}
+			}
+		case "SetSize":
+			return func(args []reflect.Value) []reflect.Value {
+				p, err := xtypes.FieldAddr(args[0].Interface(), 0)
+				if err != nil {
+					panic(err)
+				}
+				reflect.ValueOf(p).Elem().Set(args[1])
+				return nil
+			}
+		}
+		return nil
+	}, nil, nil)
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("t1").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	v := reflect.New(rt).Elem()
+	v.Field(0).Set(reflect.New(rt.Field(0).Type.Elem()))
+	for i := 1; i <= 2; i++ {
+		v.MethodByName("SetSize").Call([]reflect.Value{reflect.ValueOf(i)})
+		if r := v.MethodByName("Size").Call(nil); r[0].Int() != int64(i) {
+			t.Errorf("call promoted Size: %v", r[0])
+		}
+	}
+
+	pkg, err = makePkg(dddTest)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx = xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		return func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(args[1].Len())}
+		}
+	}, nil, nil)
+	rt, err = xtypes.ToType(pkg.Scope().Lookup("U").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	v = reflect.New(rt).Elem()
+	v.Field(0).Set(reflect.New(rt.Field(0).Type.Elem()))
+	if r := v.MethodByName("Sum").Call([]reflect.Value{reflect.ValueOf(1), reflect.ValueOf(2)}); r[0].Int() != 2 {
+		t.Errorf("call promoted variadic Sum: %v", r[0])
+	}
+}
+
+var embeddedBenchTypes map[string]reflect.Type
+
+// benchmarkEmbedded calls String on a zero value of name, one of the
+// types of structTest, through fmt.Stringer.
+func benchmarkEmbedded(b *testing.B, name string) {
+	if embeddedBenchTypes == nil {
+		pkg, err := makePkg(structTest)
+		if err != nil {
+			b.Fatalf("makePkg error %s", err)
+		}
+		ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+			return func(args []reflect.Value) []reflect.Value {
+				return []reflect.Value{reflect.Zero(mtyp.Out(0))}
+			}
+		}, nil, nil)
+		embeddedBenchTypes = make(map[string]reflect.Type)
+		for _, name := range []string{"T", "t1", "t2", "t3"} {
+			rt, err := xtypes.ToType(pkg.Scope().Lookup(name).Type(), ctx)
+			if err != nil {
+				b.Fatalf("ToType error %v", err)
+			}
+			embeddedBenchTypes[name] = rt
+		}
+	}
+	rt := embeddedBenchTypes[name]
+	v := reflect.New(rt).Elem()
+	switch name {
+	case "t2":
+		v.Field(0).Set(reflect.New(embeddedBenchTypes["T"]))
+	case "t3":
+		v.Field(0).Set(reflect.New(embeddedBenchTypes["T"]).Elem())
+	}
+	s := v.Interface().(fmt.Stringer)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = s.String()
+	}
+}
+
+func BenchmarkMethodDirect(b *testing.B) {
+	benchmarkEmbedded(b, "T")
+}
+
+func BenchmarkMethodPromoted(b *testing.B) {
+	benchmarkEmbedded(b, "t1")
+}
+
+func BenchmarkMethodPromotedPtr(b *testing.B) {
+	benchmarkEmbedded(b, "t2")
+}
+
+func BenchmarkMethodPromotedIface(b *testing.B) {
+	benchmarkEmbedded(b, "t3")
+}
+
+var nilEmbeddedSrc = `
+package main
+
+type N struct {
+	size int
+}
+
+func (n N) Size() int {
+	return n.size
+}
+
+func (n *N) IsNil() bool {
+	return n == nil
+}
+
+type T struct {
+	*N
+}
+
+type U struct {
+	*T
+}
+
+type Sizer interface {
+	Size() int
+}
+
+type V struct {
+	Sizer
+}
+`
+
+type nilN struct {
+	size int
+}
+
+func (n nilN) Size() int {
+	return n.size
+}
+
+func (n *nilN) IsNil() bool {
+	return n == nil
+}
+
+type nilT struct {
+	*nilN
+}
+
+type nilU struct {
+	*nilT
+}
+
+type nilSizer interface {
+	Size() int
+}
+
+type nilV struct {
+	nilSizer
+}
+
+// callPanic calls fn and returns what it panics with, as a string.
+func callPanic(fn func()) (r string) {
+	defer func() {
+		switch e := recover().(type) {
+		case nil:
+		case runtime.Error:
+			r = "runtime.Error: " + e.Error()
+		default:
+			r = fmt.Sprint(e)
+		}
+	}()
+	fn()
+	return
+}
+
+func TestNilEmbeddedPointer(t *testing.T) {
+	pkg, err := makePkg(nilEmbeddedSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		switch method.Name() {
+		case "Size":
+			return func(args []reflect.Value) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(int(args[0].Field(0).Int()))}
+			}
+		case "IsNil":
+			return func(args []reflect.Value) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(args[0].IsNil())}
+			}
+		}
+		return nil
+	}, nil, nil)
+	toType := func(name string) reflect.Type {
+		rt, err := xtypes.ToType(pkg.Scope().Lookup(name).Type(), ctx)
+		if err != nil {
+			t.Fatalf("ToType error %v", err)
+		}
+		return rt
+	}
+	tt, ut, vt := toType("T"), toType("U"), toType("V")
+	u := reflect.New(ut).Elem()
+	u.Field(0).Set(reflect.New(tt))
+	tests := []struct {
+		name   string
+		v      reflect.Value
+		method string
+		native func()
+	}{
+		{"T{nil}", reflect.New(tt).Elem(), "Size", func() { nilT{}.Size() }},
+		{"T{nil}", reflect.New(tt).Elem(), "IsNil", func() { nilT{}.IsNil() }},
+		{"U{nil}", reflect.New(ut).Elem(), "Size", func() { nilU{}.Size() }},
+		{"U{nil}", reflect.New(ut).Elem(), "IsNil", func() { nilU{}.IsNil() }},
+		{"U{&T{nil}}", u, "Size", func() { nilU{&nilT{}}.Size() }},
+		{"U{&T{nil}}", u, "IsNil", func() { nilU{&nilT{}}.IsNil() }},
+		{"&U{nil}", reflect.New(ut), "Size", func() { (&nilU{}).Size() }},
+		{"V{nil}", reflect.New(vt).Elem(), "Size", func() { nilV{}.Size() }},
+	}
+	for _, test := range tests {
+		want := callPanic(test.native)
+		got := callPanic(func() { test.v.MethodByName(test.method).Call(nil) })
+		if got != want {
+			t.Errorf("%s.%s: got panic %q, want %q", test.name, test.method, got, want)
+		}
+	}
+	if r := u.MethodByName("IsNil").Call(nil); !r[0].Bool() {
+		t.Error("U{&T{nil}}.IsNil must get a nil receiver")
+	}
+	n := reflect.New(tt.Field(0).Type.Elem())
+	if err := xtypes.SetField(n.Interface(), 0, 3); err != nil {
+		t.Fatalf("SetField error %v", err)
+	}
+	u.Field(0).Elem().Field(0).Set(n)
+	if r := u.MethodByName("Size").Call(nil); r[0].Int() != 3 {
+		t.Errorf("U{&T{&N{3}}}.Size: %v", r[0])
+	}
+}
+
+func TestMethodIndex(t *testing.T) {
+	pkg, err := makePkg(methodSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("T").Type(), xtypes.NewContext(nil, nil, nil))
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	typ := pkg.Scope().Lookup("T").Type()
+	for _, rt := range []reflect.Type{rt, reflect.PtrTo(rt)} {
+		mset := types.NewMethodSet(typ)
+		if rt.Kind() == reflect.Ptr {
+			mset = types.NewMethodSet(types.NewPointer(typ))
+		}
+		if n := mset.Len(); n != rt.NumMethod() {
+			t.Fatalf("%v: num method %v, want %v", rt, rt.NumMethod(), n)
+		}
+		for i := 0; i < mset.Len(); i++ {
+			fn := mset.At(i).Obj().(*types.Func)
+			index, ok := xtypes.MethodIndex(rt, fn)
+			if !ok || rt.Method(index).Name != fn.Name() {
+				t.Errorf("%v: MethodIndex(%v) = %v, %v", rt, fn.Name(), index, ok)
+				continue
+			}
+			if f, ok := xtypes.MethodFunc(rt, index); !ok || f != fn {
+				t.Errorf("%v: MethodFunc(%v) = %v, %v", rt, index, f, ok)
+			}
+		}
+	}
+	setSize := types.NewMethodSet(types.NewPointer(typ)).Lookup(pkg, "SetSize").Obj().(*types.Func)
+	if _, ok := xtypes.MethodIndex(rt, setSize); ok {
+		t.Error("SetSize must not be in the value method set")
+	}
+	if _, ok := xtypes.MethodFunc(rt, rt.NumMethod()); ok {
+		t.Error("MethodFunc must fail out of range")
+	}
+}
+
+var methodIfaceSrc = `
+package main
+
+type I interface {
+	Size() int
+	name() string
+}
+
+type J interface {
+	I
+	Close() error
+}
+
+var x interface {
+	Size() int
+	name() string
+}
+`
+
+func TestMethodIndexInterface(t *testing.T) {
+	pkg, err := makePkg(methodIfaceSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(nil, nil, nil)
+	for _, name := range []string{"I", "J", "x"} {
+		typ := pkg.Scope().Lookup(name).Type()
+		rt, err := xtypes.ToType(typ, ctx)
+		if err != nil {
+			t.Fatalf("ToType error %v", err)
+		}
+		mset := types.NewMethodSet(typ)
+		if n := mset.Len(); n != rt.NumMethod() {
+			t.Fatalf("%v: num method %v, want %v", rt, rt.NumMethod(), n)
+		}
+		for i := 0; i < mset.Len(); i++ {
+			fn := mset.At(i).Obj().(*types.Func)
+			index, ok := xtypes.MethodIndex(rt, fn)
+			if !ok || rt.Method(index).Name != fn.Name() {
+				t.Errorf("%v: MethodIndex(%v) = %v, %v", rt, fn.Name(), index, ok)
+				continue
+			}
+			info, ok := xtypes.MethodInfoOf(rt, index)
+			if !ok || info.Func.Id() != fn.Id() || info.Source != xtypes.SourceInterface {
+				t.Errorf("%v: MethodInfoOf(%v) = %+v, %v", rt, index, info, ok)
+			}
+		}
+	}
+}
+
+func TestMethodInfoOf(t *testing.T) {
+	pkg, err := makePkg(methodSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		if method.Name() == "Name" {
+			return constMethod("")
+		}
+		return nil
+	}, nil, nil)
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("T").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	nt, err := xtypes.ToType(pkg.Scope().Lookup("N").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	tests := []struct {
+		rt       reflect.Type
+		method   string
+		recv     string
+		embedded string
+		source   xtypes.MethodSource
+	}{
+		{rt, "Name", "main.T", "", xtypes.SourceFindMethod},
+		{nt, "Size", "main.N", "", xtypes.SourceNone},
+		{rt, "Size", "main.N", "N", xtypes.SourcePromoted},
+		{reflect.PtrTo(rt), "Name", "main.T", "", xtypes.SourceFindMethod},
+		{reflect.PtrTo(rt), "SetSize", "*main.N", "N", xtypes.SourcePromoted},
+	}
+	for _, test := range tests {
+		m, ok := test.rt.MethodByName(test.method)
+		if !ok {
+			t.Fatalf("%v: method %v not found", test.rt, test.method)
+		}
+		info, ok := xtypes.MethodInfoOf(test.rt, m.Index)
+		if !ok {
+			t.Errorf("%v.%v: no info", test.rt, test.method)
+			continue
+		}
+		var embedded []string
+		for _, field := range info.Embedded {
+			embedded = append(embedded, field.Name())
+		}
+		recv := info.Func.Type().(*types.Signature).Recv().Type().String()
+		if info.Func.Name() != test.method || recv != test.recv || strings.Join(embedded, ".") != test.embedded ||
+			info.Source != test.source {
+			t.Errorf("%v.%v: bad info %v %v %v %v", test.rt, test.method, info.Func, recv, embedded, info.Source)
+		}
+	}
+	if _, ok := xtypes.MethodInfoOf(reflect.TypeOf(0), 0); ok {
+		t.Error("MethodInfoOf must fail for a type not made by ToType")
+	}
+}
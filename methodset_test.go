@@ -0,0 +1,260 @@
+package xtypes_test
+
+import (
+	"fmt"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goplus/xtypes"
+)
+
+var methodSetSrc = `
+package main
+
+type N struct{ n int }
+
+func (N) Size() int   { return 1 }
+func (*N) SetSize(int) {}
+
+type M struct{ m int }
+
+func (M) Size() int { return 2 }
+func (M) Len() int  { return 2 }
+
+type Sizer interface{ Size() int }
+
+type A struct {
+	N
+	M
+}
+
+type R struct {
+	*R
+	N
+}
+
+func (R) Len() int { return 3 }
+
+type I struct {
+	Sizer
+	N
+}
+
+type J struct {
+	Sizer
+	D
+}
+
+type D struct{ N }
+
+type P *N
+
+type U N
+
+type W A
+
+type PN = *N
+
+type E struct {
+	PN
+}
+
+type X struct {
+	*Y
+}
+
+type Y struct {
+	*X
+	M
+}
+
+type T struct{ *T }
+
+func (T) Get() int  { return 5 }
+func (*T) Set(int) {}
+
+type S []int
+
+func (s S) Len() int { return 4 }
+
+type K struct {
+	S
+	*D
+}
+`
+
+type msN struct{ n int }
+
+func (msN) Size() int    { return 1 }
+func (*msN) SetSize(int) {}
+
+type msM struct{ m int }
+
+func (msM) Size() int { return 2 }
+func (msM) Len() int  { return 2 }
+
+type msSizer interface{ Size() int }
+
+type msA struct {
+	msN
+	msM
+}
+
+type msR struct {
+	*msR
+	msN
+}
+
+func (msR) Len() int { return 3 }
+
+type msI struct {
+	msSizer
+	msN
+}
+
+type msJ struct {
+	msSizer
+	msD
+}
+
+type msD struct{ msN }
+
+type msP *msN
+
+type msU msN
+
+type msW msA
+
+type msPN = *msN
+
+type msE struct {
+	msPN
+}
+
+type msX struct {
+	*msY
+}
+
+type msY struct {
+	*msX
+	msM
+}
+
+type msT struct{ *msT }
+
+func (msT) Get() int { return 5 }
+func (*msT) Set(int) {}
+
+type msS []int
+
+func (s msS) Len() int { return 4 }
+
+type msK struct {
+	msS
+	*msD
+}
+
+// TestMethodSetEdges checks the method sets of converted types, and calls
+// of their methods on zero values, against compiled Go.
+func TestMethodSetEdges(t *testing.T) {
+	pkg, err := makePkg(methodSetSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		var r int
+		switch strings.TrimPrefix(method.FullName(), "(main.") {
+		case "N).Size":
+			r = 1
+		case "M).Size", "M).Len":
+			r = 2
+		case "R).Len":
+			r = 3
+		case "S).Len":
+			r = 4
+		case "T).Get":
+			r = 5
+		default:
+			return func(args []reflect.Value) []reflect.Value {
+				return nil
+			}
+		}
+		return constMethod(r)
+	}, nil, nil)
+	tests := []struct {
+		name   string
+		native reflect.Type
+	}{
+		{"A", reflect.TypeOf(msA{})},
+		{"R", reflect.TypeOf(msR{})},
+		{"I", reflect.TypeOf(msI{})},
+		{"J", reflect.TypeOf(msJ{})},
+		{"P", reflect.TypeOf(msP(nil))},
+		{"U", reflect.TypeOf(msU{})},
+		{"W", reflect.TypeOf(msW{})},
+		{"E", reflect.TypeOf(msE{})},
+		{"X", reflect.TypeOf(msX{})},
+		{"Y", reflect.TypeOf(msY{})},
+		{"K", reflect.TypeOf(msK{})},
+		{"T", reflect.TypeOf(msT{})},
+	}
+	for _, test := range tests {
+		rt, err := xtypes.ToType(pkg.Scope().Lookup(test.name).Type(), ctx)
+		if err != nil {
+			t.Fatalf("%v: ToType error %v", test.name, err)
+		}
+		for _, ptr := range []bool{false, true} {
+			rt, native := rt, test.native
+			if ptr {
+				rt, native = reflect.PtrTo(rt), reflect.PtrTo(native)
+			}
+			if got, want := methodNames(rt), methodNames(native); got != want {
+				t.Errorf("%v: methods %v, want %v", rt, got, want)
+				continue
+			}
+			for i := 0; i < native.NumMethod(); i++ {
+				got := callZero(rt, i)
+				want := callZero(native, i)
+				if got != want {
+					t.Errorf("%v.%v: got %v, want %v", rt, native.Method(i).Name, got, want)
+				}
+			}
+		}
+	}
+}
+
+func methodNames(rt reflect.Type) string {
+	var names []string
+	for i := 0; i < rt.NumMethod(); i++ {
+		names = append(names, rt.Method(i).Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// callZero calls the method i of a zero value of rt, a new value if rt is a
+// pointer, and returns its results or what it panics with as a string.
+func callZero(rt reflect.Type, i int) (r string) {
+	v := reflect.New(rt).Elem()
+	if rt.Kind() == reflect.Ptr {
+		v = reflect.New(rt.Elem())
+	}
+	m := v.Method(i)
+	args := make([]reflect.Value, m.Type().NumIn())
+	for i := range args {
+		args[i] = reflect.Zero(m.Type().In(i))
+	}
+	if p := callPanic(func() { r = fmtValues(m.Call(args)) }); p != "" {
+		return "panic: " + p
+	}
+	return
+}
+
+func fmtValues(values []reflect.Value) string {
+	var s []string
+	for _, v := range values {
+		s = append(s, fmt.Sprint(v.Interface()))
+	}
+	return strings.Join(s, ",")
+}
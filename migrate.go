@@ -0,0 +1,279 @@
+/*
+ Copyright 2020 The GoPlus Authors (goplus.org)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package xtypes
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	xcall "github.com/goplus/xtypes/internal/reflect"
+)
+
+// MigratePolicy controls how Migrate handles a value that cannot be
+// carried over to its new type.
+type MigratePolicy int
+
+const (
+	// MigrateZero leaves the value zero.
+	MigrateZero MigratePolicy = iota
+	// MigrateStrict makes Migrate fail.
+	MigrateStrict
+)
+
+// Migrate copies v into a new value of newType, typically the redefined
+// version of v's type. Struct fields are matched by name, unexported ones
+// included: new fields are left zero and removed fields are dropped.
+// Migrate recurses through pointers, slices, arrays and maps, keeping
+// their aliasing and cycles. A value held in an interface is migrated too
+// when its type has a counterpart, that is a type of the same name
+// reachable from newType through struct fields, pointers, slices, arrays
+// and maps, or a pointer, slice, array or map of such types.
+// Values of identical types are shared, numeric values are converted, and
+// any other mismatch, like arrays of different lengths, is handled
+// according to policy.
+func Migrate(v reflect.Value, newType reflect.Type, policy MigratePolicy) (reflect.Value, error) {
+	m := &migrator{
+		policy:  policy,
+		visited: make(map[visit]reflect.Value),
+		named:   make(map[typeName]reflect.Type),
+	}
+	m.collectNamed(newType)
+	r := reflect.New(newType).Elem()
+	if err := m.migrate(r, xcall.Unrestricted(v), "v"); err != nil {
+		return reflect.Value{}, err
+	}
+	return r, nil
+}
+
+// visit is a reference value visited by a deep copy.
+type visit struct {
+	ptr unsafe.Pointer
+	len int
+	typ reflect.Type
+}
+
+type migrator struct {
+	policy  MigratePolicy
+	visited map[visit]reflect.Value   // (old ptr, new type) => new value
+	named   map[typeName]reflect.Type // named types reachable from the new type
+}
+
+type typeName struct {
+	pkgPath string
+	name    string
+}
+
+// collectNamed records the named types reachable from t.
+func (m *migrator) collectNamed(t reflect.Type) {
+	if t.Name() != "" {
+		key := typeName{t.PkgPath(), t.Name()}
+		if _, ok := m.named[key]; ok {
+			return
+		}
+		m.named[key] = t
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		m.collectNamed(t.Elem())
+	case reflect.Map:
+		m.collectNamed(t.Key())
+		m.collectNamed(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			m.collectNamed(t.Field(i).Type)
+		}
+	}
+}
+
+// counterpart returns the new type of t, a named type of the same name
+// reachable from the new type, or a composite type of such types.
+func (m *migrator) counterpart(t reflect.Type) (reflect.Type, bool) {
+	if t.Name() != "" {
+		nt, ok := m.named[typeName{t.PkgPath(), t.Name()}]
+		return nt, ok
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		if elem, ok := m.counterpart(t.Elem()); ok {
+			return reflect.PtrTo(elem), true
+		}
+	case reflect.Slice:
+		if elem, ok := m.counterpart(t.Elem()); ok {
+			return reflect.SliceOf(elem), true
+		}
+	case reflect.Array:
+		if elem, ok := m.counterpart(t.Elem()); ok {
+			return reflect.ArrayOf(t.Len(), elem), true
+		}
+	case reflect.Map:
+		key, ok1 := m.counterpart(t.Key())
+		elem, ok2 := m.counterpart(t.Elem())
+		if ok1 || ok2 {
+			if !ok1 {
+				key = t.Key()
+			}
+			if !ok2 {
+				elem = t.Elem()
+			}
+			return reflect.MapOf(key, elem), true
+		}
+	}
+	return nil, false
+}
+
+func (m *migrator) migrate(dst, src reflect.Value, path string) error {
+	if !src.IsValid() {
+		return nil
+	}
+	st, dt := src.Type(), dst.Type()
+	if st == dt && st.Kind() != reflect.Interface {
+		dst.Set(src)
+		return nil
+	}
+	switch dt.Kind() {
+	case reflect.Struct:
+		if st.Kind() != reflect.Struct {
+			break
+		}
+		for i := 0; i < dt.NumField(); i++ {
+			name := dt.Field(i).Name
+			for j := 0; j < st.NumField(); j++ {
+				if st.Field(j).Name != name {
+					continue
+				}
+				if err := m.migrate(xcall.Field(dst, i), xcall.Field(src, j), path+"."+name); err != nil {
+					return err
+				}
+				break
+			}
+		}
+		return nil
+	case reflect.Ptr:
+		if st.Kind() != reflect.Ptr {
+			break
+		}
+		if src.IsNil() {
+			return nil
+		}
+		// the elem type makes the key, as pointer types to the same
+		// dynamic type may differ
+		key := visit{unsafe.Pointer(src.Pointer()), 0, dt.Elem()}
+		if p, ok := m.visited[key]; ok {
+			dst.Set(p)
+			return nil
+		}
+		p := reflect.New(dt.Elem())
+		m.visited[key] = p
+		dst.Set(p)
+		return m.migrate(p.Elem(), src.Elem(), "(*"+path+")")
+	case reflect.Slice:
+		if st.Kind() != reflect.Slice {
+			break
+		}
+		if src.IsNil() {
+			return nil
+		}
+		key := visit{unsafe.Pointer(src.Pointer()), src.Len(), dt}
+		if s, ok := m.visited[key]; ok {
+			dst.Set(s)
+			return nil
+		}
+		s := reflect.MakeSlice(dt, src.Len(), src.Cap())
+		m.visited[key] = s
+		dst.Set(s)
+		for i := 0; i < src.Len(); i++ {
+			if err := m.migrate(s.Index(i), src.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Array:
+		if st.Kind() != reflect.Array {
+			break
+		}
+		if src.Len() != dst.Len() && m.policy == MigrateStrict {
+			return fmt.Errorf("migrate %s: cannot use %v as %v", path, st, dt)
+		}
+		for i := 0; i < src.Len() && i < dst.Len(); i++ {
+			if err := m.migrate(dst.Index(i), src.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if st.Kind() != reflect.Map {
+			break
+		}
+		if src.IsNil() {
+			return nil
+		}
+		key := visit{unsafe.Pointer(src.Pointer()), 0, dt}
+		if mv, ok := m.visited[key]; ok {
+			dst.Set(mv)
+			return nil
+		}
+		mv := reflect.MakeMapWithSize(dt, src.Len())
+		m.visited[key] = mv
+		dst.Set(mv)
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(dt.Key()).Elem()
+			if err := m.migrate(k, iter.Key(), fmt.Sprintf("%s[%v]", path, iter.Key())); err != nil {
+				return err
+			}
+			e := reflect.New(dt.Elem()).Elem()
+			if err := m.migrate(e, iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key())); err != nil {
+				return err
+			}
+			mv.SetMapIndex(k, e)
+		}
+		return nil
+	case reflect.Interface:
+		if st.Kind() == reflect.Interface {
+			if src.IsNil() {
+				return nil
+			}
+			src = src.Elem()
+			st = src.Type()
+		}
+		if nt, ok := m.counterpart(st); ok && nt.AssignableTo(dt) {
+			e := reflect.New(nt).Elem()
+			if err := m.migrate(e, src, path+".("+nt.String()+")"); err != nil {
+				return err
+			}
+			dst.Set(e)
+			return nil
+		}
+	}
+	if st.AssignableTo(dt) {
+		dst.Set(src)
+		return nil
+	}
+	if isNumber(st.Kind()) && isNumber(dt.Kind()) && st.ConvertibleTo(dt) {
+		dst.Set(src.Convert(dt))
+		return nil
+	}
+	if m.policy == MigrateStrict {
+		return fmt.Errorf("migrate %s: cannot use %v as %v", path, st, dt)
+	}
+	return nil
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Complex128
+}
@@ -0,0 +1,174 @@
+package xtypes_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/goplus/xtypes"
+)
+
+var migrateTestOld = `
+package main
+
+type Node struct {
+	Name  string
+	id    int
+	old   bool
+	next  *Node
+	kids  []*Node
+	attrs map[string]*Node
+	val   interface{}
+}
+`
+
+var migrateTestNew = `
+package main
+
+type Node struct {
+	id    int64
+	Name  string
+	added []string
+	next  *Node
+	kids  []*Node
+	attrs map[string]*Node
+	val   interface{}
+}
+`
+
+func TestMigrate(t *testing.T) {
+	toType := func(src string) reflect.Type {
+		pkg, err := makePkg(src)
+		if err != nil {
+			t.Fatalf("makePkg error %s", err)
+		}
+		rt, err := xtypes.ToType(pkg.Scope().Lookup("Node").Type(), xtypes.NewContext(nil, nil, nil))
+		if err != nil {
+			t.Fatalf("ToType error %v", err)
+		}
+		return rt
+	}
+	oldType := toType(migrateTestOld)
+	newType := toType(migrateTestNew)
+
+	setField := func(v reflect.Value, i int, x reflect.Value) {
+		p, err := xtypes.FieldAddr(v.Addr().Interface(), i)
+		if err != nil {
+			t.Fatalf("FieldAddr error %v", err)
+		}
+		reflect.ValueOf(p).Elem().Set(x)
+	}
+	root := reflect.New(oldType)
+	kid := reflect.New(oldType)
+	root.Elem().Field(0).SetString("root")
+	setField(root.Elem(), 1, reflect.ValueOf(1))
+	setField(root.Elem(), 2, reflect.ValueOf(true))
+	setField(root.Elem(), 3, root)
+	setField(kid.Elem(), 3, root)
+	kids := reflect.MakeSlice(oldType.Field(4).Type, 1, 1)
+	kids.Index(0).Set(kid)
+	setField(root.Elem(), 4, kids)
+	attrs := reflect.MakeMap(oldType.Field(5).Type)
+	attrs.SetMapIndex(reflect.ValueOf("kid"), kid)
+	setField(root.Elem(), 5, attrs)
+	setField(root.Elem(), 6, kid)
+
+	v, err := xtypes.Migrate(root, reflect.PtrTo(newType), xtypes.MigrateStrict)
+	if err != nil {
+		t.Fatalf("Migrate error %v", err)
+	}
+	n := v.Elem()
+	if id := n.Field(0).Int(); id != 1 {
+		t.Errorf("id %v", id)
+	}
+	if name := n.Field(1).String(); name != "root" {
+		t.Errorf("name %v", name)
+	}
+	if !n.Field(2).IsNil() {
+		t.Error("added field must be zero")
+	}
+	if n.Field(3).Pointer() != v.Pointer() {
+		t.Error("cycle not kept")
+	}
+	nkid := n.Field(4).Index(0)
+	if nkid.Elem().Type() != newType || nkid.Elem().Field(3).Pointer() != v.Pointer() {
+		t.Error("slice element not migrated")
+	}
+	if n.Field(5).MapIndex(reflect.ValueOf("kid")).Pointer() != nkid.Pointer() {
+		t.Error("aliasing not kept")
+	}
+	if val := n.Field(6).Elem(); val.Type().Elem() != newType || val.Pointer() != nkid.Pointer() {
+		t.Errorf("value in interface not migrated: %v", val.Type())
+	}
+
+	if _, err := xtypes.Migrate(reflect.ValueOf("s"), reflect.TypeOf(0), xtypes.MigrateStrict); err == nil {
+		t.Error("must fail with MigrateStrict")
+	}
+	if r, err := xtypes.Migrate(reflect.ValueOf("s"), reflect.TypeOf(0), xtypes.MigrateZero); err != nil || r.Int() != 0 {
+		t.Errorf("MigrateZero %v %v", r, err)
+	}
+	if _, err := xtypes.Migrate(reflect.ValueOf([2]int{1, 2}), reflect.TypeOf([3]int{}), xtypes.MigrateStrict); err == nil {
+		t.Error("arrays of different lengths must fail with MigrateStrict")
+	}
+	if r, err := xtypes.Migrate(reflect.ValueOf([2]int{1, 2}), reflect.TypeOf([3]int{}), xtypes.MigrateZero); err != nil || r.Index(1).Int() != 2 {
+		t.Errorf("MigrateZero array %v %v", r, err)
+	}
+}
+
+var migrateIfaceOld = `
+package main
+
+type U struct {
+	N int
+}
+
+type W struct {
+	val interface{}
+	u   U
+}
+`
+
+var migrateIfaceNew = `
+package main
+
+type U struct {
+	N int64
+}
+
+type W struct {
+	val interface{}
+	u   U
+}
+`
+
+func TestMigrateInterfaceFirst(t *testing.T) {
+	toType := func(src string) reflect.Type {
+		pkg, err := makePkg(src)
+		if err != nil {
+			t.Fatalf("makePkg error %s", err)
+		}
+		rt, err := xtypes.ToType(pkg.Scope().Lookup("W").Type(), xtypes.NewContext(nil, nil, nil))
+		if err != nil {
+			t.Fatalf("ToType error %v", err)
+		}
+		return rt
+	}
+	oldType, newType := toType(migrateIfaceOld), toType(migrateIfaceNew)
+	w := reflect.New(oldType).Elem()
+	u := reflect.New(oldType.Field(1).Type).Elem()
+	u.Field(0).SetInt(1)
+	if err := xtypes.SetField(w.Addr().Interface(), 0, u.Interface()); err != nil {
+		t.Fatalf("SetField error %v", err)
+	}
+	// the interface field comes before the field of type U
+	v, err := xtypes.Migrate(w, newType, xtypes.MigrateStrict)
+	if err != nil {
+		t.Fatalf("Migrate error %v", err)
+	}
+	val, err := xtypes.Field(v.Interface(), 0)
+	if err != nil {
+		t.Fatalf("Field error %v", err)
+	}
+	if rt := reflect.TypeOf(val); rt != newType.Field(1).Type || reflect.ValueOf(val).Field(0).Int() != 1 {
+		t.Errorf("value in interface not migrated: %v %v", rt, val)
+	}
+}
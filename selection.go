@@ -0,0 +1,121 @@
+/*
+ Copyright 2020 The GoPlus Authors (goplus.org)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package xtypes
+
+import (
+	"errors"
+	"fmt"
+	"go/types"
+	"reflect"
+
+	xcall "github.com/goplus/xtypes/internal/reflect"
+)
+
+// Select applies sel, a selection x.f of types.Info.Selections, to v, the
+// value of x, with the implicit dereferences and address-taking of
+// compiled code:
+//
+//   - for a field selector, it returns the field, addressable if v is
+//     addressable or a pointer is on the way, so that &x.f is its Addr;
+//   - for a method value, it returns the method bound to a copy of its
+//     receiver, evaluated at once;
+//   - for a method expression, v is any value of the type T of T.f, like
+//     reflect.Zero(T), and it returns a func taking the receiver first.
+func Select(v reflect.Value, sel *types.Selection) (reflect.Value, error) {
+	index := sel.Index()
+	switch sel.Kind() {
+	case types.FieldVal:
+		return selectField(v, index)
+	case types.MethodVal:
+		return selectMethod(v, index, sel.Obj().(*types.Func))
+	case types.MethodExpr:
+		return selectMethodExpr(v.Type(), index, sel.Obj().(*types.Func))
+	}
+	return reflect.Value{}, fmt.Errorf("unknown selection kind %v", sel.Kind())
+}
+
+// selectField returns the field of v selected by index.
+func selectField(v reflect.Value, index []int) (reflect.Value, error) {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, ErrNilPointer
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return v, fmt.Errorf("%v - %w", v.Type(), ErrNotStruct)
+		}
+		v = xcall.Field(v, i)
+	}
+	return v, nil
+}
+
+// selectMethod returns the method fn of v, promoted through the embedded
+// fields of index, bound to its receiver.
+func selectMethod(v reflect.Value, index []int, fn *types.Func) (reflect.Value, error) {
+	recv, err := selectField(v, index[:len(index)-1])
+	if err != nil {
+		return recv, err
+	}
+	if recv.Kind() != reflect.Ptr && recv.Kind() != reflect.Interface &&
+		isPointer(fn.Type().(*types.Signature).Recv().Type()) {
+		if !recv.CanAddr() {
+			return recv, fmt.Errorf("cannot call pointer method `%s` on %v", fn.Name(), recv.Type())
+		}
+		recv = recv.Addr()
+	}
+	return MethodValue(recv, fn)
+}
+
+// selectMethodExpr returns the method fn of rt, promoted through the
+// embedded fields of index, as a func taking the receiver first.
+func selectMethodExpr(rt reflect.Type, index []int, fn *types.Func) (reflect.Value, error) {
+	t := rt
+	for _, i := range index[:len(index)-1] {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		t = t.Field(i).Type
+	}
+	if t.Kind() != reflect.Interface {
+		if isPointer(fn.Type().(*types.Signature).Recv().Type()) {
+			if t.Kind() != reflect.Ptr {
+				t = reflect.PtrTo(t)
+			}
+		} else if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	_, mtyp, err := lookupMethod(t, fn)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	variadic := mtyp.IsVariadic()
+	return reflect.MakeFunc(methodExprType(rt, mtyp), func(args []reflect.Value) []reflect.Value {
+		m, err := selectMethod(args[0], index, fn)
+		if errors.Is(err, ErrNilPointer) {
+			nilPointerDereference()
+		} else if err != nil {
+			panic(err)
+		}
+		if variadic {
+			return m.CallSlice(args[1:])
+		}
+		return m.Call(args[1:])
+	}), nil
+}
@@ -0,0 +1,128 @@
+package xtypes_test
+
+import (
+	"errors"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"github.com/goplus/xtypes"
+)
+
+var selectionSrc = `
+package main
+
+type N struct {
+	n int
+}
+
+func (n N) Size() int {
+	return n.n
+}
+
+func (n *N) SetSize(size int) {
+	n.n = size
+}
+
+type T struct {
+	*N
+	name string
+}
+
+var (
+	t T
+	p = &t
+	_ = t.n
+	_ = p.name
+	_ = t.Size
+	_ = p.SetSize
+	_ = T.Size
+	_ = (*T).SetSize
+)
+`
+
+func TestSelect(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, selectionSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile error %v", err)
+	}
+	info := &types.Info{Selections: make(map[*ast.SelectorExpr]*types.Selection)}
+	conf := types.Config{Importer: importer.Default()}
+	pkg, err := conf.Check(file.Name.Name, fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatalf("Check error %v", err)
+	}
+	sels := make(map[string]*types.Selection)
+	for expr, sel := range info.Selections {
+		sels[types.ExprString(expr)] = sel
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		if method.Name() == "Size" {
+			return func(args []reflect.Value) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(int(args[0].Field(0).Int()))}
+			}
+		}
+		return func(args []reflect.Value) []reflect.Value {
+			if err := xtypes.SetField(args[0].Interface(), 0, int(args[1].Int())); err != nil {
+				panic(err)
+			}
+			return nil
+		}
+	}, nil, nil)
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("T").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	p := reflect.New(rt)
+	v := p.Elem()
+	if _, err := xtypes.Select(v, sels["t.n"]); !errors.Is(err, xtypes.ErrNilPointer) {
+		t.Errorf("t.n through a nil embedded pointer: %v", err)
+	}
+	v.Field(0).Set(reflect.New(rt.Field(0).Type.Elem()))
+	n, err := xtypes.Select(v, sels["t.n"])
+	if err != nil || !n.CanSet() {
+		t.Fatalf("t.n: %v %v", n, err)
+	}
+	n.SetInt(1)
+	if name, err := xtypes.Select(p, sels["p.name"]); err != nil || !name.CanAddr() {
+		t.Errorf("p.name: %v %v", name, err)
+	}
+	size, err := xtypes.Select(v, sels["t.Size"])
+	if err != nil {
+		t.Fatalf("t.Size: %v", err)
+	}
+	setSize, err := xtypes.Select(p, sels["p.SetSize"])
+	if err != nil {
+		t.Fatalf("p.SetSize: %v", err)
+	}
+	setSize.Call([]reflect.Value{reflect.ValueOf(2)})
+	if n.Int() != 2 {
+		t.Errorf("p.SetSize: n %v", n.Int())
+	}
+	if r := size.Call(nil); r[0].Int() != 1 {
+		t.Errorf("t.Size must be bound to a copy of its receiver: %v", r[0].Int())
+	}
+	sizeExpr, err := xtypes.Select(reflect.Zero(rt), sels["T.Size"])
+	if err != nil {
+		t.Fatalf("T.Size: %v", err)
+	}
+	if r := sizeExpr.Call([]reflect.Value{v}); r[0].Int() != 2 {
+		t.Errorf("T.Size: %v", r[0].Int())
+	}
+	setSizeExpr, err := xtypes.Select(reflect.Zero(reflect.PtrTo(rt)), sels["(*T).SetSize"])
+	if err != nil {
+		t.Fatalf("(*T).SetSize: %v", err)
+	}
+	setSizeExpr.Call([]reflect.Value{p, reflect.ValueOf(3)})
+	if n.Int() != 3 {
+		t.Errorf("(*T).SetSize: n %v", n.Int())
+	}
+	if r := callPanic(func() { sizeExpr.Call([]reflect.Value{reflect.Zero(rt)}) }); r == "" {
+		t.Error("T.Size must panic through a nil embedded pointer")
+	}
+}
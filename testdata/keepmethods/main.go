@@ -0,0 +1,43 @@
+// Command keepmethods calls methods of host types through xtypes only, to
+// check that the linker keeps their method data without any use of
+// reflect's own method lookups in the program.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/goplus/xtypes"
+)
+
+type Ctr struct {
+	n int
+}
+
+func (c *Ctr) Inc() int {
+	c.n++
+	return c.n
+}
+
+type Val struct {
+	n int
+}
+
+func (v Val) Get() int {
+	return v.n
+}
+
+func main() {
+	for _, v := range []interface{}{&Ctr{n: 1}, Val{n: 3}} {
+		name := "Inc"
+		if _, ok := v.(Val); ok {
+			name = "Get"
+		}
+		r, err := xtypes.CallMethod(v, "", name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(name, r[0])
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"unsafe"
 
 	"github.com/goplus/reflectx"
+	xcall "github.com/goplus/xtypes/internal/reflect"
 )
 
 var basicTypes = [...]reflect.Type{
@@ -146,6 +147,25 @@ func ToType(typ types.Type, ctx Context) (reflect.Type, error) {
 	return nil, fmt.Errorf("unknown type %v", typ)
 }
 
+// Zero returns the zero value of typ, converted with ToType. The method set
+// of a named type is installed, so the value is ready for MethodByName.
+func Zero(typ types.Type, ctx Context) (reflect.Value, error) {
+	t, err := ToType(typ, ctx)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("zero value of `%v` - %w", typ, err)
+	}
+	return reflect.Zero(t), nil
+}
+
+// New returns a pointer to a new zero value of typ, converted with ToType.
+func New(typ types.Type, ctx Context) (reflect.Value, error) {
+	t, err := ToType(typ, ctx)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("new value of `%v` - %w", typ, err)
+	}
+	return reflect.New(t), nil
+}
+
 var (
 	sigMap = make(map[string]reflect.Type)
 )
@@ -173,8 +193,12 @@ func toStructType(t *types.Struct, ctx Context) (typ reflect.Type, err error) {
 		}
 	}
 	typ = reflectx.StructOf(flds)
-	typ, _ = toMethodSet(t, typ, ctx)
+	typ, _, err = toMethodSet(t, typ, ctx)
+	if err != nil {
+		return nil, err
+	}
 	//ctx.UpdateType(typ, fnUpdate)
+	setOrigin(ctx, typ, t)
 	return typ, nil
 }
 
@@ -197,11 +221,11 @@ func toStructField(v *types.Var, tag string, ctx Context) (fld reflect.StructFie
 	return
 }
 
-func toMethodSet(t types.Type, styp reflect.Type, ctx Context) (reflect.Type, func() error) {
+func toMethodSet(t types.Type, styp reflect.Type, ctx Context) (reflect.Type, func() error, error) {
 	methods := IntuitiveMethodSet(t)
 	numMethods := len(methods)
 	if numMethods == 0 {
-		return styp, nil
+		return styp, nil, nil
 	}
 	var mcount, pcount int
 	for i := 0; i < numMethods; i++ {
@@ -213,8 +237,23 @@ func toMethodSet(t types.Type, styp reflect.Type, ctx Context) (reflect.Type, fu
 		pcount++
 	}
 	typ := reflectx.NewMethodSet(styp, mcount, pcount)
-	fn := func() error {
+	fn := updateMethodSet(t, typ, methods, ctx)
+	if err := fn(); err != nil {
+		return nil, nil, err
+	}
+	if base, ok := baseContext(ctx); ok {
+		base.msets[typ] = fn
+	}
+	return typ, fn, nil
+}
+
+// updateMethodSet returns the func that (re)installs methods on typ, the
+// method set type created for t.
+func updateMethodSet(t types.Type, typ reflect.Type, methods []*types.Selection, ctx Context) func() error {
+	numMethods := len(methods)
+	return func() error {
 		var ms []reflectx.Method
+		var infos []*MethodInfo
 		for i := 0; i < numMethods; i++ {
 			fn := methods[i].Obj().(*types.Func)
 			sig := methods[i].Type().(*types.Signature)
@@ -223,34 +262,45 @@ func toMethodSet(t types.Type, styp reflect.Type, ctx Context) (reflect.Type, fu
 			if err != nil {
 				return fmt.Errorf("named methods `%s.%s` - %w", t, fn.Name(), err)
 			}
-			var mfn func(args []reflect.Value) []reflect.Value
-			if ctx != nil {
-				idx := methods[i].Index()
-				//				pkgPath := typ.PkgPath()
-				if len(idx) > 1 {
-					isptr := isPointer(fn.Type().Underlying().(*types.Signature).Recv().Type())
-					mfn = func(args []reflect.Value) []reflect.Value {
-						this := args[0].FieldByIndex(idx[:len(idx)-1])
-						if isptr && this.Kind() != reflect.Ptr {
-							this = this.Addr()
-						}
-						m := methodByName(this, fn.Name())
-						return callValue(m, args[1:])
-					}
-				} else {
-					mfn = ctx.FindMethod(mtyp, fn)
-				}
+			info := &MethodInfo{
+				Func:      fn,
+				Type:      mtyp,
+				Recv:      typ,
+				Pointer:   pointer,
+				Selection: methods[i],
+				Index:     methods[i].Index(),
+				Embedded:  embeddedFields(t, methods[i].Index()),
+			}
+			if pointer {
+				info.Recv = reflect.PtrTo(typ)
 			}
+			mfn, err := findMethodValue(info, ctx)
+			if err != nil {
+				return err
+			}
+			if mfn == nil {
+				mfn = bindMethod(info, ctx)
+			}
+			mfn = interceptMethod(ctx, info, mfn)
+			info.fn = mfn
+			infos = append(infos, info)
 			var pkgpath string
 			if pkg := fn.Pkg(); pkg != nil {
 				pkgpath = pkg.Path()
 			}
 			ms = append(ms, reflectx.MakeMethod(fn.Name(), pkgpath, pointer, mtyp, mfn))
 		}
-		return reflectx.SetMethodSet(typ, ms, false)
+		if err := reflectx.SetMethodSet(typ, ms, false); err != nil {
+			return err
+		}
+		for _, info := range infos {
+			if info.value.IsValid() {
+				xcall.SetMethodFunc(typ, info.Func.Name(), info.value)
+			}
+		}
+		setMethodInfos(typ, infos)
+		return nil
 	}
-	fn()
-	return typ, fn
 }
 
 func toNamedType(t *types.Named, ctx Context) (reflect.Type, error) {
@@ -272,9 +322,15 @@ func toNamedType(t *types.Named, ctx Context) (reflect.Type, error) {
 	}
 	typ := reflectx.NamedTypeOf(name.Pkg().Path(), name.Name(), utype)
 	var fnUpdate func() error
-	if typ.Kind() != reflect.Interface {
-		typ, fnUpdate = toMethodSet(t, typ, ctx)
+	if typ.Kind() == reflect.Interface {
+		setInterfaceMethodInfos(t, typ)
+	} else {
+		typ, fnUpdate, err = toMethodSet(t, typ, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("named type `%s` - %w", name.Name(), err)
+		}
 	}
+	setOrigin(ctx, typ, t)
 	ctx.UpdateType(name, typ, fnUpdate)
 	return typ, nil
 }
@@ -304,7 +360,10 @@ func toInterfaceType(t *types.Interface, ctx Context) (reflect.Type, error) {
 			ms[i].PkgPath = pkg.Path()
 		}
 	}
-	return reflectx.InterfaceOf(nil, ms), nil
+	typ := reflectx.InterfaceOf(nil, ms)
+	setInterfaceMethodInfos(t, typ)
+	setOrigin(ctx, typ, t)
+	return typ, nil
 }
 
 // Context interface
@@ -315,6 +374,28 @@ type Context interface {
 	UpdateType(name *types.TypeName, typ reflect.Type, fnUpdateMethods func() error)
 }
 
+// Wrapper is the optional interface of a Context that wraps another one,
+// like a MethodBinder built on the Context made by NewContext. The types
+// converted with a Context that is or unwraps to the one made by
+// NewContext get its origins, method slots and interceptors.
+type Wrapper interface {
+	Unwrap() Context
+}
+
+// OriginFinder is the optional interface of a Context that maps converted
+// types back to go/types. The Context made by NewContext implements it.
+type OriginFinder interface {
+	Origin(rt reflect.Type) (types.Type, *types.TypeName, bool)
+}
+
+// the optional interfaces implemented by the Context made by NewContext
+var (
+	_ Updater      = (*context)(nil)
+	_ Rebinder     = (*context)(nil)
+	_ Intercepter  = (*context)(nil)
+	_ OriginFinder = (*context)(nil)
+)
+
 type typeScope struct {
 	rtype map[reflect.Type]reflect.Type // pre_type => type
 }
@@ -322,19 +403,48 @@ type typeScope struct {
 type context struct {
 	scope        map[*types.Scope]*typeScope
 	ntype        map[reflect.Type](func() error) // type => update_methods
+	msets        map[reflect.Type](func() error) // type => install_methods, unnamed types included
+	slots        map[*types.Func]*methodSlot
+	origin       map[reflect.Type]types.Type
 	findMethod   func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value
 	findTypeName func(name *types.TypeName) (reflect.Type, bool)
 	findType     func(typ types.Type) (reflect.Type, bool)
+	interceptors []Interceptor
+	lazy         bool
+	fset         *token.FileSet
+}
+
+// ContextOption configures a Context created by NewContext.
+type ContextOption func(ctx *context)
+
+// WithLazyMethods makes the Context look up method implementations on their
+// first call instead of when ToType installs them.
+func WithLazyMethods() ContextOption {
+	return func(ctx *context) {
+		ctx.lazy = true
+	}
+}
+
+// WithFileSet sets the file set used to report the position of a method
+// declaration when the method has no implementation.
+func WithFileSet(fset *token.FileSet) ContextOption {
+	return func(ctx *context) {
+		ctx.fset = fset
+	}
 }
 
 func NewContext(
 	findMethod func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value,
 	findTypeName func(name *types.TypeName) (reflect.Type, bool),
 	findType func(typ types.Type) (reflect.Type, bool),
+	opts ...ContextOption,
 ) Context {
 	ctx := &context{
 		scope:        make(map[*types.Scope]*typeScope),
 		ntype:        make(map[reflect.Type](func() error)),
+		msets:        make(map[reflect.Type](func() error)),
+		slots:        make(map[*types.Func]*methodSlot),
+		origin:       make(map[reflect.Type]types.Type),
 		findMethod:   findMethod,
 		findTypeName: findTypeName,
 		findType:     findType,
@@ -354,11 +464,27 @@ func NewContext(
 			return nil, false
 		}
 	}
+	for _, opt := range opts {
+		opt(ctx)
+	}
 	return ctx
 }
 
 func (t *context) FindMethod(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
-	return t.findMethod(mtyp, method)
+	return t.methodSlot(method, func() func(args []reflect.Value) []reflect.Value {
+		return t.findMethod(mtyp, method)
+	}).call
+}
+
+// methodSlot returns the slot of method, whose implementation is found by
+// resolve if the slot is new.
+func (t *context) methodSlot(method *types.Func, resolve func() func(args []reflect.Value) []reflect.Value) *methodSlot {
+	slot, ok := t.slots[method]
+	if !ok {
+		slot = newMethodSlot(resolve, notImplemented(method, t.fset), t.lazy)
+		t.slots[method] = slot
+	}
+	return slot
 }
 
 func (t *context) FindType(typ types.Type) (reflect.Type, bool) {
@@ -420,6 +546,13 @@ func (t *context) FindTypeName(name *types.TypeName) (reflect.Type, bool) {
 	return t.findScope(name.Parent()).FindTypeName(name)
 }
 
+// installMethods installs the methods of the types converted so far again.
+func (t *context) installMethods() {
+	for _, install := range t.msets {
+		install()
+	}
+}
+
 func (t *context) UpdateType(name *types.TypeName, typ reflect.Type, fnUpdateMethods func() error) {
 	t.findScope(name.Parent()).UpdateType(typ)
 	if fnUpdateMethods != nil {
@@ -430,6 +563,45 @@ func (t *context) UpdateType(name *types.TypeName, typ reflect.Type, fnUpdateMet
 	}
 }
 
+// Origin returns the type rt was converted from, and its type name if it is
+// a named type. It knows about the named, struct and interface types
+// converted with the Context.
+func (t *context) Origin(rt reflect.Type) (types.Type, *types.TypeName, bool) {
+	typ, ok := t.origin[rt]
+	if !ok {
+		return nil, nil, false
+	}
+	var name *types.TypeName
+	if named, ok := typ.(*types.Named); ok {
+		name = named.Obj()
+	}
+	return typ, name, true
+}
+
+// setOrigin records typ as the type rt was converted from, if ctx is or
+// unwraps to a Context made by NewContext.
+func setOrigin(ctx Context, rt reflect.Type, typ types.Type) {
+	if t, ok := baseContext(ctx); ok {
+		t.origin[rt] = typ
+	}
+}
+
+// baseContext returns the context made by NewContext that ctx is, or that
+// ctx unwraps to.
+func baseContext(ctx Context) (*context, bool) {
+	for ctx != nil {
+		switch c := ctx.(type) {
+		case *context:
+			return c, true
+		case Wrapper:
+			ctx = c.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
 // golang.org/x/tools/go/types/typeutil.IntuitiveMethodSet
 func IntuitiveMethodSet(T types.Type) []*types.Selection {
 	isPointerToConcrete := func(T types.Type) bool {
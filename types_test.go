@@ -1,6 +1,7 @@
 package xtypes_test
 
 import (
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/importer"
@@ -18,7 +19,10 @@ import (
 const filename = "<src>"
 
 func makePkg(src string) (*types.Package, error) {
-	fset := token.NewFileSet()
+	return makePkgWithFileSet(token.NewFileSet(), src)
+}
+
+func makePkgWithFileSet(fset *token.FileSet, src string) (*types.Package, error) {
 	file, err := parser.ParseFile(fset, filename, src, parser.DeclarationErrors)
 	if err != nil {
 		return nil, err
@@ -873,3 +877,102 @@ func TestTypesObject(t *testing.T) {
 		t.Error("bad typ Implements")
 	}
 }
+
+func TestOrigin(t *testing.T) {
+	pkg, err := makePkg(structTest)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(nil, nil, nil)
+	for _, name := range []string{"T", "Stringer"} {
+		obj := pkg.Scope().Lookup(name)
+		rt, err := xtypes.ToType(obj.Type(), ctx)
+		if err != nil {
+			t.Fatalf("ToType error %v", err)
+		}
+		typ, tname, ok := ctx.(xtypes.OriginFinder).Origin(rt)
+		if !ok || typ != obj.Type() || tname != obj {
+			t.Errorf("%v: bad origin %v %v %v", name, typ, tname, ok)
+		}
+	}
+	// a Context unwrapping to ctx records the origins in ctx
+	obj := pkg.Scope().Lookup("t1")
+	rt, err := xtypes.ToType(obj.Type(), fieldContext{ctx})
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	if typ, tname, ok := ctx.(xtypes.OriginFinder).Origin(rt); !ok || typ != obj.Type() || tname != nil {
+		t.Errorf("t1: bad origin %v %v %v", typ, tname, ok)
+	}
+	if _, _, ok := ctx.(xtypes.OriginFinder).Origin(reflect.TypeOf(0)); ok {
+		t.Error("Origin must fail for a type not converted")
+	}
+}
+
+// fieldContext forwards to the Context of its field.
+type fieldContext struct {
+	ctx xtypes.Context
+}
+
+func (p fieldContext) FindType(typ types.Type) (reflect.Type, bool) {
+	return p.ctx.FindType(typ)
+}
+
+func (p fieldContext) FindTypeName(name *types.TypeName) (reflect.Type, bool) {
+	return p.ctx.FindTypeName(name)
+}
+
+func (p fieldContext) FindMethod(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+	return p.ctx.FindMethod(mtyp, method)
+}
+
+func (p fieldContext) UpdateType(name *types.TypeName, typ reflect.Type, fnUpdateMethods func() error) {
+	p.ctx.UpdateType(name, typ, fnUpdateMethods)
+}
+
+func (p fieldContext) Unwrap() xtypes.Context {
+	return p.ctx
+}
+
+func TestZeroNew(t *testing.T) {
+	pkg, err := makePkg(methodSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		return func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(method.Name())}
+		}
+	}, nil, nil)
+	typ := pkg.Scope().Lookup("T").Type()
+	v, err := xtypes.Zero(typ, ctx)
+	if err != nil {
+		t.Fatalf("Zero error %v", err)
+	}
+	if v.CanSet() || v.Type().Name() != "T" {
+		t.Errorf("bad zero value %v", v.Type())
+	}
+	if r := v.MethodByName("Name").Call(nil); r[0].String() != "Name" {
+		t.Errorf("call Name: %v", r[0])
+	}
+	p, err := xtypes.New(typ, ctx)
+	if err != nil {
+		t.Fatalf("New error %v", err)
+	}
+	if p.Type().Elem() != v.Type() || !p.Elem().CanSet() {
+		t.Errorf("bad new value %v", p.Type())
+	}
+	if p.MethodByName("SetSize").Type().NumIn() != 1 {
+		t.Error("SetSize not in the method set of *T")
+	}
+
+	bad := types.NewStruct([]*types.Var{
+		types.NewField(0, pkg, "x", types.NewSlice(types.Typ[types.UntypedInt]), false),
+	}, nil)
+	for _, fn := range []func(types.Type, xtypes.Context) (reflect.Value, error){xtypes.Zero, xtypes.New} {
+		_, err := fn(bad, ctx)
+		if !errors.Is(err, xtypes.ErrUntyped) || !strings.Contains(err.Error(), "field `x`") {
+			t.Errorf("bad error %v", err)
+		}
+	}
+}
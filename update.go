@@ -0,0 +1,247 @@
+/*
+ Copyright 2020 The GoPlus Authors (goplus.org)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package xtypes
+
+import (
+	"go/types"
+	"reflect"
+)
+
+// Updater is the optional interface of a Context that carries converted
+// types over to a new type-check of a package. The Context made by
+// NewContext implements it.
+type Updater interface {
+	Update(oldPkg, newPkg *types.Package) []*types.TypeName
+}
+
+// Update moves the types converted for oldPkg over to newPkg, a new
+// type-check of the same package. Type names are matched by name. A
+// converted reflect.Type is reused when its declaration, methods included,
+// is structurally unchanged and only refers to unchanged types of the
+// package; its methods are bound again to the declarations of newPkg.
+// Update returns the type names of newPkg whose declaration changed, their
+// reflect.Type is rebuilt by the next ToType.
+func (t *context) Update(oldPkg, newPkg *types.Package) []*types.TypeName {
+	d := newPkgDiff(oldPkg, newPkg)
+	var changed []*types.TypeName
+	for _, name := range newPkg.Scope().Names() {
+		if d.changed[name] {
+			changed = append(changed, d.pairs[name][1])
+		}
+	}
+	scope, ok := t.scope[oldPkg.Scope()]
+	if !ok {
+		return changed
+	}
+	delete(t.scope, oldPkg.Scope())
+	nscope := t.findScope(newPkg.Scope())
+	var fns []func() error
+	for k, v := range scope.rtype {
+		if v == nil {
+			continue
+		}
+		name := k.Name()
+		if _, ok := d.pairs[name]; !ok || d.changed[name] {
+			delete(t.ntype, v)
+			continue
+		}
+		nscope.rtype[k] = v
+		named := d.pairs[name][1].Type().(*types.Named)
+		t.origin[v] = named
+		if v.Kind() == reflect.Interface {
+			setInterfaceMethodInfos(named, v)
+		}
+		if _, ok := t.ntype[v]; ok {
+			fn := updateMethodSet(named, v, IntuitiveMethodSet(named), t)
+			t.ntype[v] = fn
+			t.msets[v] = fn
+			fns = append(fns, fn)
+		}
+	}
+	for _, fn := range fns {
+		fn()
+	}
+	return changed
+}
+
+// pkgDiff compares the type declarations of two type-checks of a package.
+type pkgDiff struct {
+	oldPkg  *types.Package
+	newPkg  *types.Package
+	pairs   map[string][2]*types.TypeName // name => (old, new)
+	changed map[string]bool
+}
+
+func newPkgDiff(oldPkg, newPkg *types.Package) *pkgDiff {
+	d := &pkgDiff{
+		oldPkg:  oldPkg,
+		newPkg:  newPkg,
+		pairs:   make(map[string][2]*types.TypeName),
+		changed: make(map[string]bool),
+	}
+	for _, name := range newPkg.Scope().Names() {
+		nobj, ok := newPkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok || nobj.IsAlias() {
+			continue
+		}
+		oobj, ok := oldPkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok || oobj.IsAlias() {
+			continue
+		}
+		d.pairs[name] = [2]*types.TypeName{oobj, nobj}
+	}
+	// a declaration also changes when a type it refers to changes
+	for {
+		n := len(d.changed)
+		for name, pair := range d.pairs {
+			if !d.changed[name] && !d.identicalDecl(pair[0], pair[1]) {
+				d.changed[name] = true
+			}
+		}
+		if len(d.changed) == n {
+			break
+		}
+	}
+	return d
+}
+
+func (d *pkgDiff) identicalDecl(x, y *types.TypeName) bool {
+	xt, ok := x.Type().(*types.Named)
+	if !ok {
+		return false
+	}
+	yt, ok := y.Type().(*types.Named)
+	if !ok {
+		return false
+	}
+	if !d.identical(xt.Underlying(), yt.Underlying()) {
+		return false
+	}
+	n := yt.NumMethods()
+	if xt.NumMethods() != n {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		ym := yt.Method(i)
+		var xm *types.Func
+		for j := 0; j < n; j++ {
+			if m := xt.Method(j); m.Name() == ym.Name() {
+				xm = m
+				break
+			}
+		}
+		if xm == nil {
+			return false
+		}
+		xsig := xm.Type().(*types.Signature)
+		ysig := ym.Type().(*types.Signature)
+		if isPointer(xsig.Recv().Type()) != isPointer(ysig.Recv().Type()) {
+			return false
+		}
+		if !d.identical(xsig, ysig) {
+			return false
+		}
+	}
+	return true
+}
+
+// identical is like types.Identical, but named types of the compared
+// packages are identical if they have the same name and are unchanged.
+func (d *pkgDiff) identical(x, y types.Type) bool {
+	switch x := x.(type) {
+	case *types.Basic:
+		y, ok := y.(*types.Basic)
+		return ok && x.Kind() == y.Kind()
+	case *types.Pointer:
+		y, ok := y.(*types.Pointer)
+		return ok && d.identical(x.Elem(), y.Elem())
+	case *types.Slice:
+		y, ok := y.(*types.Slice)
+		return ok && d.identical(x.Elem(), y.Elem())
+	case *types.Array:
+		y, ok := y.(*types.Array)
+		return ok && x.Len() == y.Len() && d.identical(x.Elem(), y.Elem())
+	case *types.Map:
+		y, ok := y.(*types.Map)
+		return ok && d.identical(x.Key(), y.Key()) && d.identical(x.Elem(), y.Elem())
+	case *types.Chan:
+		y, ok := y.(*types.Chan)
+		return ok && x.Dir() == y.Dir() && d.identical(x.Elem(), y.Elem())
+	case *types.Struct:
+		y, ok := y.(*types.Struct)
+		if !ok || x.NumFields() != y.NumFields() {
+			return false
+		}
+		for i := 0; i < x.NumFields(); i++ {
+			xf, yf := x.Field(i), y.Field(i)
+			if xf.Name() != yf.Name() || xf.Anonymous() != yf.Anonymous() || x.Tag(i) != y.Tag(i) {
+				return false
+			}
+			if !d.identical(xf.Type(), yf.Type()) {
+				return false
+			}
+		}
+		return true
+	case *types.Signature:
+		y, ok := y.(*types.Signature)
+		return ok && x.Variadic() == y.Variadic() &&
+			d.identicalTuple(x.Params(), y.Params()) &&
+			d.identicalTuple(x.Results(), y.Results())
+	case *types.Interface:
+		y, ok := y.(*types.Interface)
+		if !ok || x.NumMethods() != y.NumMethods() {
+			return false
+		}
+		for i := 0; i < x.NumMethods(); i++ {
+			xm, ym := x.Method(i), y.Method(i)
+			if xm.Name() != ym.Name() || !d.identical(xm.Type(), ym.Type()) {
+				return false
+			}
+		}
+		return true
+	case *types.Named:
+		y, ok := y.(*types.Named)
+		if !ok {
+			return false
+		}
+		xobj, yobj := x.Obj(), y.Obj()
+		if xobj.Name() != yobj.Name() {
+			return false
+		}
+		if xobj.Pkg() == nil || yobj.Pkg() == nil {
+			return xobj == yobj
+		}
+		if xobj.Pkg() == d.oldPkg && yobj.Pkg() == d.newPkg {
+			_, ok := d.pairs[xobj.Name()]
+			return ok && !d.changed[xobj.Name()]
+		}
+		return xobj.Pkg().Path() == yobj.Pkg().Path()
+	}
+	return false
+}
+
+func (d *pkgDiff) identicalTuple(x, y *types.Tuple) bool {
+	if x.Len() != y.Len() {
+		return false
+	}
+	for i := 0; i < x.Len(); i++ {
+		if !d.identical(x.At(i).Type(), y.At(i).Type()) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package xtypes_test
+
+import (
+	"go/types"
+	"reflect"
+	"testing"
+
+	"github.com/goplus/xtypes"
+)
+
+var updateTestOld = `
+package main
+
+type Point struct {
+	X int
+	Y int
+}
+
+func (p Point) Name() string {
+	return "old"
+}
+
+type Size struct {
+	W int
+	H int
+}
+
+type Rect struct {
+	Min  Point
+	Size Size
+}
+`
+
+var updateTestNew = `
+package main
+
+type Point struct {
+	X int
+	Y int
+}
+
+func (p Point) Name() string {
+	return "new"
+}
+
+type Size struct {
+	W int
+	H int
+	D int
+}
+
+type Rect struct {
+	Min  Point
+	Size Size
+}
+`
+
+func TestUpdate(t *testing.T) {
+	oldPkg, err := makePkg(updateTestOld)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	newPkg, err := makePkg(updateTestNew)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	var bound *types.Func
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		bound = method
+		return func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(method.Name())}
+		}
+	}, nil, nil)
+	oldTypes := make(map[string]reflect.Type)
+	for _, name := range []string{"Point", "Size", "Rect"} {
+		rt, err := xtypes.ToType(oldPkg.Scope().Lookup(name).Type(), ctx)
+		if err != nil {
+			t.Fatalf("ToType error %v", err)
+		}
+		oldTypes[name] = rt
+	}
+	changed := ctx.(xtypes.Updater).Update(oldPkg, newPkg)
+	if len(changed) != 2 || changed[0].Name() != "Rect" || changed[1].Name() != "Size" {
+		t.Fatalf("bad changed types %v", changed)
+	}
+	if changed[0] != newPkg.Scope().Lookup("Rect") {
+		t.Error("changed types must be objects of the new package")
+	}
+	if bound == nil || bound.Pkg() != newPkg {
+		t.Error("methods of reused types must be bound to the new package")
+	}
+	for _, name := range []string{"Point", "Size", "Rect"} {
+		rt, err := xtypes.ToType(newPkg.Scope().Lookup(name).Type(), ctx)
+		if err != nil {
+			t.Fatalf("ToType error %v", err)
+		}
+		if reuse := rt == oldTypes[name]; reuse != (name == "Point") {
+			t.Errorf("%v: reuse %v", name, reuse)
+		}
+		if _, tname, _ := ctx.(xtypes.OriginFinder).Origin(rt); tname != newPkg.Scope().Lookup(name) {
+			t.Errorf("%v: origin %v must be in the new package", name, tname)
+		}
+		if name == "Rect" && rt.Field(0).Type != oldTypes["Point"] {
+			t.Errorf("%v: field must refer to the reused type", name)
+		}
+		if name == "Size" && rt.NumField() != 3 {
+			t.Errorf("%v: num field %v", name, rt.NumField())
+		}
+	}
+}
@@ -2,34 +2,486 @@ package xtypes
 
 import (
 	"errors"
+	"fmt"
+	"go/types"
 	"reflect"
+	"strings"
 
 	xcall "github.com/goplus/xtypes/internal/reflect"
 )
 
+var (
+	// ErrNilPointer error
+	ErrNilPointer = errors.New("invalid memory address or nil pointer dereference")
+	// ErrNotStruct error
+	ErrNotStruct = errors.New("not a struct")
+	// ErrFieldIndex error
+	ErrFieldIndex = errors.New("field index out of range")
+	// ErrMethodDropped error
+	ErrMethodDropped = errors.New("method dropped by the linker")
+)
+
+// FieldAddr returns the address of the struct field of index i of what v
+// points to, unexported fields included.
 func FieldAddr(v interface{}, index int) (interface{}, error) {
-	x := xcall.ValueOf(v).Elem()
-	if !x.IsValid() {
-		return nil, errors.New("invalid memory address or nil pointer dereference")
-	}
-	return x.Field(index).Addr().Interface(), nil
+	return FieldAddrByIndex(v, []int{index}, false)
 }
 
+// Field returns the struct field of index i of v, or of what v points to,
+// unexported fields included.
 func Field(v interface{}, index int) (interface{}, error) {
-	x := xcall.ValueOf(v)
-	for x.Kind() == xcall.Ptr {
+	return FieldByIndex(v, []int{index})
+}
+
+// FieldAddrByIndex is like FieldAddr for the nested field of the index
+// path, like types.Selection.Index. Pointers and interfaces on the way are
+// dereferenced, and nil embedded pointers are allocated if alloc is set.
+func FieldAddrByIndex(v interface{}, index []int, alloc bool) (interface{}, error) {
+	x, err := fieldByIndex(reflect.ValueOf(v), index, alloc)
+	if err != nil {
+		return nil, err
+	}
+	if !x.CanAddr() {
+		return nil, fmt.Errorf("cannot take the address of a field of %T", v)
+	}
+	return x.Addr().Interface(), nil
+}
+
+// FieldByIndex is like Field for the nested field of the index path, like
+// types.Selection.Index. Pointers and interfaces on the way are
+// dereferenced.
+func FieldByIndex(v interface{}, index []int) (interface{}, error) {
+	x, err := fieldByIndex(reflect.ValueOf(v), index, false)
+	if err != nil {
+		return nil, err
+	}
+	return x.Interface(), nil
+}
+
+func fieldByIndex(x reflect.Value, index []int, alloc bool) (reflect.Value, error) {
+	for _, i := range index {
+		var err error
+		if x, err = structValue(x, alloc); err != nil {
+			return x, err
+		}
+		if i < 0 || i >= x.NumField() {
+			return x, fmt.Errorf("field %d of %v - %w", i, x.Type(), ErrFieldIndex)
+		}
+		x = xcall.Field(x, i)
+	}
+	return x, nil
+}
+
+// structValue returns the struct x is, points to or holds. Nil pointers
+// that can be set are allocated if alloc is set.
+func structValue(x reflect.Value, alloc bool) (reflect.Value, error) {
+	for {
+		switch x.Kind() {
+		case reflect.Struct:
+			return x, nil
+		case reflect.Ptr:
+			if x.IsNil() {
+				if !alloc || !x.CanSet() {
+					return x, ErrNilPointer
+				}
+				x.Set(reflect.New(x.Type().Elem()))
+			}
+			x = x.Elem()
+		case reflect.Interface:
+			if x.IsNil() {
+				return x, ErrNilPointer
+			}
+			x = x.Elem()
+		case reflect.Invalid:
+			return x, ErrNilPointer
+		default:
+			return x, fmt.Errorf("%v - %w", x.Type(), ErrNotStruct)
+		}
+	}
+}
+
+// FieldByName returns the struct field of v, or of what v points to, with
+// the given name, unexported and promoted fields included. Embedded
+// pointers on the way are dereferenced.
+func FieldByName(v interface{}, name string) (interface{}, error) {
+	x, err := fieldByName(reflect.ValueOf(v), name)
+	if err != nil {
+		return nil, err
+	}
+	return x.Interface(), nil
+}
+
+// FieldByPath is like FieldByName for a path of field names separated by
+// dots, like "a.b.c". Pointers on the way are dereferenced.
+func FieldByPath(v interface{}, path string) (interface{}, error) {
+	x := reflect.ValueOf(v)
+	for _, name := range strings.Split(path, ".") {
+		var err error
+		if x, err = fieldByName(x, name); err != nil {
+			return nil, err
+		}
+	}
+	return x.Interface(), nil
+}
+
+func fieldByName(x reflect.Value, name string) (reflect.Value, error) {
+	x, err := structValue(x, false)
+	if err != nil {
+		return x, err
+	}
+	f, ok := x.Type().FieldByName(name)
+	if !ok {
+		return x, fmt.Errorf("%v has no field `%s`", x.Type(), name)
+	}
+	return fieldByIndex(x, f.Index, false)
+}
+
+// Index returns the i'th element of v, an array, a slice or a string, or a
+// pointer to an array.
+func Index(v interface{}, i int) (interface{}, error) {
+	x, err := indirect(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	switch x.Kind() {
+	case reflect.Array, reflect.Slice, reflect.String:
+	default:
+		return nil, fmt.Errorf("cannot index %v", x.Type())
+	}
+	if i < 0 || i >= x.Len() {
+		return nil, fmt.Errorf("index out of range [%d] with length %d", i, x.Len())
+	}
+	return x.Index(i).Interface(), nil
+}
+
+// MapIndex returns the element of v, a map or a pointer to a map, for key.
+// Like m[key], it returns the zero value if key is not in the map.
+func MapIndex(v interface{}, key interface{}) (interface{}, error) {
+	x, err := indirect(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	if x.Kind() != reflect.Map {
+		return nil, fmt.Errorf("cannot index %v", x.Type())
+	}
+	k, err := assignable(key, x.Type().Key())
+	if err != nil {
+		return nil, err
+	}
+	e := x.MapIndex(k)
+	if !e.IsValid() {
+		e = reflect.Zero(x.Type().Elem())
+	}
+	return e.Interface(), nil
+}
+
+// SetField assigns value to the struct field of index i of what ptr points
+// to, unexported fields included.
+func SetField(ptr interface{}, index int, value interface{}) error {
+	x := reflect.ValueOf(ptr)
+	if x.Kind() != reflect.Ptr {
+		return fmt.Errorf("cannot assign field of non-pointer %T", ptr)
+	}
+	f, err := fieldByIndex(x, []int{index}, false)
+	if err != nil {
+		return err
+	}
+	if !f.CanSet() {
+		return fmt.Errorf("cannot assign field of unaddressable %v", x.Type())
+	}
+	y, err := assignable(value, f.Type())
+	if err != nil {
+		return err
+	}
+	f.Set(y)
+	return nil
+}
+
+// SetIndex assigns value to the i'th element of v, a slice or a pointer to
+// an array.
+func SetIndex(v interface{}, i int, value interface{}) error {
+	x, err := indirect(reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+	switch x.Kind() {
+	case reflect.Slice:
+	case reflect.Array:
+		if !x.CanSet() {
+			return fmt.Errorf("cannot assign element of unaddressable %v", x.Type())
+		}
+	default:
+		return fmt.Errorf("cannot assign element of %v", x.Type())
+	}
+	if i < 0 || i >= x.Len() {
+		return fmt.Errorf("index out of range [%d] with length %d", i, x.Len())
+	}
+	y, err := assignable(value, x.Type().Elem())
+	if err != nil {
+		return err
+	}
+	x.Index(i).Set(y)
+	return nil
+}
+
+// SetMapIndex assigns value to the element of v, a map or a pointer to a
+// map, for key.
+func SetMapIndex(v interface{}, key interface{}, value interface{}) error {
+	x, err := indirect(reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+	if x.Kind() != reflect.Map {
+		return fmt.Errorf("cannot assign element of %v", x.Type())
+	}
+	if x.IsNil() {
+		return errors.New("assignment to entry in nil map")
+	}
+	k, err := assignable(key, x.Type().Key())
+	if err != nil {
+		return err
+	}
+	y, err := assignable(value, x.Type().Elem())
+	if err != nil {
+		return err
+	}
+	x.SetMapIndex(k, y)
+	return nil
+}
+
+// assignable returns value as a value of typ, if it is assignable to typ.
+// A nil value is the zero value of a pointer, map, slice, func, chan or
+// interface type.
+func assignable(value interface{}, typ reflect.Type) (reflect.Value, error) {
+	y := reflect.ValueOf(value)
+	if !y.IsValid() {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface, reflect.UnsafePointer:
+			return reflect.Zero(typ), nil
+		}
+		return y, fmt.Errorf("cannot use nil as %v value", typ)
+	}
+	if !y.Type().AssignableTo(typ) {
+		return y, fmt.Errorf("cannot use %v as %v value", y.Type(), typ)
+	}
+	return y, nil
+}
+
+// indirect dereferences the pointers of x.
+func indirect(x reflect.Value) (reflect.Value, error) {
+	for x.Kind() == reflect.Ptr {
+		if x.IsNil() {
+			return x, ErrNilPointer
+		}
 		x = x.Elem()
 	}
+	return x, nil
+}
+
+// methodIndex returns the index of the method name of typ, among all its
+// methods. An unexported name must be declared in pkgPath.
+func methodIndex(typ reflect.Type, pkgPath, name string) (int, bool) {
+	return xcall.MethodIndex(typ, pkgPath, name)
+}
+
+// callMethod calls the i'th method of v, as indexed by methodIndex. The
+// last argument of a variadic method is the variadic slice.
+func callMethod(v reflect.Value, i int, args []reflect.Value, variadic bool) []reflect.Value {
+	if variadic {
+		return xcall.CallSlice(xcall.MethodValue(v, i), args)
+	}
+	return xcall.Call(xcall.MethodValue(v, i), args)
+}
+
+// MethodValue returns the method fn of v bound to v, as a func value like
+// the method value x.M. It works for types made by ToType and host types,
+// and for unexported methods too. Like compiled code, a value method is
+// bound to a copy of v, or of *v, made at once.
+func MethodValue(v reflect.Value, fn *types.Func) (reflect.Value, error) {
+	switch {
+	case v.Kind() == reflect.Interface:
+		if v.IsNil() {
+			return v, ErrNilPointer
+		}
+	case !isPointer(fn.Type().(*types.Signature).Recv().Type()):
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, ErrNilPointer
+			}
+			v = v.Elem()
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(xcall.Unrestricted(v))
+		v = c
+	}
+	if info, ok := lookupMethodInfo(v.Type(), isFunc(fn)); ok {
+		return reflect.MakeFunc(info.Type, func(args []reflect.Value) []reflect.Value {
+			return info.fn(append([]reflect.Value{v}, args...))
+		}), nil
+	}
+	i, mtyp, err := lookupMethod(v.Type(), fn)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	variadic := mtyp.IsVariadic()
+	return reflect.MakeFunc(mtyp, func(args []reflect.Value) []reflect.Value {
+		return callMethod(v, i, args, variadic)
+	}), nil
+}
+
+// MethodExpr returns the method fn of rt, or of *rt if ptr is set, as a
+// func value taking the receiver as first argument, like the method
+// expression T.M. It works for types made by ToType and host types, and for
+// unexported methods too.
+func MethodExpr(rt reflect.Type, fn *types.Func, ptr bool) (reflect.Value, error) {
+	if ptr {
+		rt = reflect.PtrTo(rt)
+	}
+	if info, ok := lookupMethodInfo(rt, isFunc(fn)); ok {
+		return reflect.MakeFunc(methodExprType(rt, info.Type), func(args []reflect.Value) []reflect.Value {
+			args[0] = methodRecv(args[0], info)
+			return info.fn(args)
+		}), nil
+	}
+	i, mtyp, err := lookupMethod(rt, fn)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	variadic := mtyp.IsVariadic()
+	return reflect.MakeFunc(methodExprType(rt, mtyp), func(args []reflect.Value) []reflect.Value {
+		return callMethod(args[0], i, args[1:], variadic)
+	}), nil
+}
+
+// CallMethod calls the method of v with the given name and arguments, and
+// returns its results. An unexported name must be declared in pkgPath. It
+// works for types made by ToType and host types. Like compiled code, it
+// fails for a pointer method of a non-pointer v, which is not addressable.
+func CallMethod(v interface{}, pkgPath, name string, args ...interface{}) ([]interface{}, error) {
+	x := reflect.ValueOf(v)
 	if !x.IsValid() {
-		return nil, errors.New("invalid memory address or nil pointer dereference")
+		return nil, fmt.Errorf("method `%s` of nil", name)
+	}
+	fn, mtyp, err := methodByName(x, pkgPath, name)
+	if err != nil {
+		if x.Kind() != reflect.Ptr {
+			if _, _, perr := methodByName(reflect.New(x.Type()), pkgPath, name); perr == nil {
+				return nil, fmt.Errorf("cannot call pointer method `%s` on %v", name, x.Type())
+			}
+		}
+		return nil, err
+	}
+	in, err := callArgs(mtyp, args)
+	if err != nil {
+		return nil, fmt.Errorf("call of method `%s` - %w", name, err)
 	}
-	return x.Field(index).Interface(), nil
+	out := fn(in)
+	r := make([]interface{}, len(out))
+	for i, v := range out {
+		r[i] = v.Interface()
+	}
+	return r, nil
 }
 
-func methodByName(v reflect.Value, name string) xcall.Value {
-	return xcall.MethodByName(v, name)
+// methodByName returns the method name of v, bound to v, and its type. A
+// variadic method takes its variadic arguments as a slice.
+func methodByName(v reflect.Value, pkgPath, name string) (func(args []reflect.Value) []reflect.Value, reflect.Type, error) {
+	if info, ok := lookupMethodInfo(v.Type(), func(fn *types.Func) bool {
+		return fn.Name() == name && (fn.Exported() || pkgPath == "" || funcPkgPath(fn) == pkgPath)
+	}); ok {
+		return func(args []reflect.Value) []reflect.Value {
+			return info.fn(append([]reflect.Value{methodRecv(v, info)}, args...))
+		}, info.Type, nil
+	}
+	i, ok := methodIndex(v.Type(), pkgPath, name)
+	if !ok {
+		return nil, nil, fmt.Errorf("method `%s` not found in %v", name, v.Type())
+	}
+	mtyp := xcall.MethodType(v.Type(), i)
+	if mtyp == nil {
+		return nil, nil, fmt.Errorf("method `%s` of %v - %w", name, v.Type(), ErrMethodDropped)
+	}
+	return func(args []reflect.Value) []reflect.Value {
+		return callMethod(v, i, args, mtyp.IsVariadic())
+	}, mtyp, nil
+}
+
+// callArgs returns args as the arguments of a call of mtyp, with the
+// variadic arguments in a slice.
+func callArgs(mtyp reflect.Type, args []interface{}) ([]reflect.Value, error) {
+	n := mtyp.NumIn()
+	if mtyp.IsVariadic() {
+		n--
+		if len(args) < n {
+			return nil, fmt.Errorf("not enough arguments, have %d, want at least %d", len(args), n)
+		}
+	} else if len(args) != n {
+		return nil, fmt.Errorf("wrong number of arguments, have %d, want %d", len(args), n)
+	}
+	in := make([]reflect.Value, n, mtyp.NumIn())
+	for i := range in {
+		x, err := assignable(args[i], mtyp.In(i))
+		if err != nil {
+			return nil, err
+		}
+		in[i] = x
+	}
+	if mtyp.IsVariadic() {
+		typ := mtyp.In(n)
+		s := reflect.MakeSlice(typ, len(args)-n, len(args)-n)
+		for i := range args[n:] {
+			x, err := assignable(args[n+i], typ.Elem())
+			if err != nil {
+				return nil, err
+			}
+			s.Index(i).Set(x)
+		}
+		in = append(in, s)
+	}
+	return in, nil
 }
 
-func callValue(v xcall.Value, args []reflect.Value) []reflect.Value {
-	return xcall.Call(v, args)
+// methodExprType returns the type of the method expression of mtyp, a
+// method of recv.
+func methodExprType(recv reflect.Type, mtyp reflect.Type) reflect.Type {
+	in := []reflect.Type{recv}
+	for i := 0; i < mtyp.NumIn(); i++ {
+		in = append(in, mtyp.In(i))
+	}
+	out := make([]reflect.Type, mtyp.NumOut())
+	for i := range out {
+		out[i] = mtyp.Out(i)
+	}
+	return reflect.FuncOf(in, out, mtyp.IsVariadic())
+}
+
+// methodRecv returns v as the receiver of the installed method info, v
+// being a pointer when info is a value method of its element.
+func methodRecv(v reflect.Value, info *MethodInfo) reflect.Value {
+	if !info.Pointer && v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			nilPointerDereference()
+		}
+		return v.Elem()
+	}
+	return v
+}
+
+// isFunc returns a func reporting whether its argument is fn.
+func isFunc(fn *types.Func) func(f *types.Func) bool {
+	return func(f *types.Func) bool {
+		return f == fn
+	}
+}
+
+func lookupMethod(rt reflect.Type, fn *types.Func) (int, reflect.Type, error) {
+	i, ok := methodIndex(rt, funcPkgPath(fn), fn.Name())
+	if !ok {
+		return 0, nil, fmt.Errorf("method `%s` not found in %v", fn.Name(), rt)
+	}
+	mtyp := xcall.MethodType(rt, i)
+	if mtyp == nil {
+		return 0, nil, fmt.Errorf("method `%s` of %v - %w", fn.Name(), rt, ErrMethodDropped)
+	}
+	return i, mtyp, nil
 }
@@ -0,0 +1,423 @@
+package xtypes_test
+
+import (
+	"errors"
+	"go/types"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/goplus/xtypes"
+)
+
+var methodExprSrc = `
+package main
+
+type T struct {
+	x int
+}
+
+func (t T) get() int {
+	return t.x
+}
+
+func (t *T) Sum(args ...int) int {
+	return 0
+}
+`
+
+type hostT struct {
+	x int
+}
+
+func (t hostT) get() int {
+	return t.x
+}
+
+func (t *hostT) Sum(args ...int) int {
+	for _, arg := range args {
+		t.x += arg
+	}
+	return t.x
+}
+
+type getter interface {
+	get() int
+}
+
+var hostGetter getter = hostT{}
+
+func TestMethodValueExpr(t *testing.T) {
+	// call get through an interface, so that the linker keeps it.
+	if hostGetter.get() != 0 {
+		t.Fatal("bad get")
+	}
+	pkg, err := makePkg(methodExprSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		switch method.Name() {
+		case "get":
+			return func(args []reflect.Value) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(int(args[0].Field(0).Int()))}
+			}
+		case "Sum":
+			return func(args []reflect.Value) []reflect.Value {
+				p, err := xtypes.FieldAddr(args[0].Interface(), 0)
+				if err != nil {
+					panic(err)
+				}
+				x := reflect.ValueOf(p).Elem()
+				for i := 0; i < args[1].Len(); i++ {
+					x.SetInt(x.Int() + args[1].Index(i).Int())
+				}
+				return []reflect.Value{reflect.ValueOf(int(x.Int()))}
+			}
+		}
+		return nil
+	}, nil, nil)
+	typ := pkg.Scope().Lookup("T").Type()
+	rt, err := xtypes.ToType(typ, ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	mset := types.NewMethodSet(types.NewPointer(typ))
+	get := mset.Lookup(pkg, "get").Obj().(*types.Func)
+	sum := mset.Lookup(pkg, "Sum").Obj().(*types.Func)
+	hostPkg := types.NewPackage(reflect.TypeOf(hostT{}).PkgPath(), "xtypes_test")
+	hostGet := types.NewFunc(0, hostPkg, "get", get.Type().(*types.Signature))
+	for _, rt := range []reflect.Type{rt, reflect.TypeOf(hostT{})} {
+		get := get
+		if rt.PkgPath() == hostPkg.Path() {
+			get = hostGet
+		}
+		v := reflect.New(rt)
+		sumv, err := xtypes.MethodValue(v, sum)
+		if err != nil {
+			t.Fatalf("%v: MethodValue error %v", rt, err)
+		}
+		if r := sumv.Call([]reflect.Value{reflect.ValueOf(1), reflect.ValueOf(2)}); r[0].Int() != 3 {
+			t.Errorf("%v: call Sum method value: %v", rt, r[0])
+		}
+		getv, err := xtypes.MethodValue(v.Elem(), get)
+		if err != nil {
+			t.Fatalf("%v: MethodValue error %v", rt, err)
+		}
+		if r := getv.Call(nil); r[0].Int() != 3 {
+			t.Errorf("%v: call get method value: %v", rt, r[0])
+		}
+		getp, err := xtypes.MethodValue(v, get)
+		if err != nil {
+			t.Fatalf("%v: MethodValue error %v", rt, err)
+		}
+		gete, err := xtypes.MethodExpr(rt, get, false)
+		if err != nil {
+			t.Fatalf("%v: MethodExpr error %v", rt, err)
+		}
+		if r := gete.Call([]reflect.Value{v.Elem()}); r[0].Int() != 3 {
+			t.Errorf("%v: call get method expr: %v", rt, r[0])
+		}
+		sume, err := xtypes.MethodExpr(rt, sum, true)
+		if err != nil {
+			t.Fatalf("%v: MethodExpr error %v", rt, err)
+		}
+		if n := sume.Type().NumIn(); n != 2 || !sume.Type().IsVariadic() {
+			t.Errorf("%v: bad method expr type %v", rt, sume.Type())
+		}
+		if r := sume.Call([]reflect.Value{v, reflect.ValueOf(4)}); r[0].Int() != 7 {
+			t.Errorf("%v: call Sum method expr: %v", rt, r[0])
+		}
+		for _, f := range []reflect.Value{getv, getp} {
+			if r := f.Call(nil); r[0].Int() != 3 {
+				t.Errorf("%v: get method value must be bound to a copy: %v", rt, r[0])
+			}
+		}
+		if _, err := xtypes.MethodValue(reflect.Zero(v.Type()), get); !errors.Is(err, xtypes.ErrNilPointer) {
+			t.Errorf("%v: MethodValue of nil pointer: %v", rt, err)
+		}
+		if _, err := xtypes.MethodExpr(rt, sum, false); err == nil {
+			t.Errorf("%v: MethodExpr must fail for a pointer method", rt)
+		}
+		other := types.NewFunc(0, types.NewPackage("other", "other"), "get", get.Type().(*types.Signature))
+		if _, err := xtypes.MethodExpr(rt, other, false); err == nil {
+			t.Errorf("%v: MethodExpr must fail for an unexported method of another package", rt)
+		}
+	}
+}
+
+type hostInner struct {
+	c []int
+	m map[string]*hostT
+}
+
+type hostOuter struct {
+	*hostInner
+	b  hostInner
+	bp *hostInner
+}
+
+var accessorSrc = `
+package main
+
+type Inner struct {
+	c []int
+	m map[string]int
+}
+
+type Outer struct {
+	*Inner
+	b  Inner
+	bp *Inner
+}
+`
+
+func TestAccessors(t *testing.T) {
+	inner := &hostInner{c: []int{1, 2}, m: map[string]*hostT{"a": {x: 3}}}
+	host := &hostOuter{hostInner: inner, b: *inner}
+	if c, err := xtypes.FieldByName(host, "c"); err != nil || len(c.([]int)) != 2 {
+		t.Errorf("FieldByName promoted c: %v %v", c, err)
+	}
+	if c, err := xtypes.FieldByPath(host, "b.c"); err != nil || len(c.([]int)) != 2 {
+		t.Errorf("FieldByPath b.c: %v %v", c, err)
+	}
+	if _, err := xtypes.FieldByPath(host, "bp.c"); err == nil {
+		t.Error("FieldByPath must fail through a nil pointer")
+	}
+	if _, err := xtypes.FieldByName(host, "d"); err == nil {
+		t.Error("FieldByName must fail for a missing field")
+	}
+	m, err := xtypes.FieldByName(host, "m")
+	if err != nil {
+		t.Fatalf("FieldByName m: %v", err)
+	}
+	if e, err := xtypes.MapIndex(m, "a"); err != nil || e.(*hostT).x != 3 {
+		t.Errorf("MapIndex a: %v %v", e, err)
+	}
+	if e, err := xtypes.MapIndex(m, "b"); err != nil || e.(*hostT) != nil {
+		t.Errorf("MapIndex b: %v %v", e, err)
+	}
+	if _, err := xtypes.MapIndex(m, nil); err == nil {
+		t.Error("MapIndex must fail for a nil string key")
+	}
+	if e, err := xtypes.MapIndex(map[*hostT]int{nil: 1}, nil); err != nil || e != 1 {
+		t.Errorf("MapIndex nil pointer key: %v %v", e, err)
+	}
+	if _, err := xtypes.MapIndex(m, 1); err == nil {
+		t.Error("MapIndex must fail for a bad key type")
+	}
+
+	pkg, err := makePkg(accessorSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(nil, nil, nil)
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("Outer").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	v := reflect.New(rt)
+	if _, err := xtypes.FieldByName(v.Interface(), "c"); err == nil {
+		t.Error("FieldByName must fail through a nil embedded pointer")
+	}
+	p, err := xtypes.FieldAddr(v.Interface(), 1)
+	if err != nil {
+		t.Fatalf("FieldAddr error %v", err)
+	}
+	c, err := xtypes.FieldAddr(p, 0)
+	if err != nil {
+		t.Fatalf("FieldAddr error %v", err)
+	}
+	reflect.ValueOf(c).Elem().Set(reflect.ValueOf([]int{4, 5}))
+	c, err = xtypes.FieldByPath(v.Interface(), "b.c")
+	if err != nil {
+		t.Fatalf("FieldByPath b.c: %v", err)
+	}
+	if e, err := xtypes.Index(c, 1); err != nil || e != 5 {
+		t.Errorf("Index 1: %v %v", e, err)
+	}
+	if _, err := xtypes.Index(c, 2); err == nil {
+		t.Error("Index must fail out of range")
+	}
+	if e, err := xtypes.Index("ab", 1); err != nil || e != byte('b') {
+		t.Errorf("Index string: %v %v", e, err)
+	}
+	if e, err := xtypes.Index(&[2]int{6, 7}, 0); err != nil || e != 6 {
+		t.Errorf("Index array pointer: %v %v", e, err)
+	}
+}
+
+func TestSetters(t *testing.T) {
+	host := &hostOuter{}
+	if err := xtypes.SetField(host, 0, &hostInner{}); err != nil || host.hostInner == nil {
+		t.Errorf("SetField 0: %v", err)
+	}
+	if err := xtypes.SetField(host, 0, nil); err != nil || host.hostInner != nil {
+		t.Errorf("SetField 0 nil: %v", err)
+	}
+	if err := xtypes.SetField(host, 1, 1); err == nil {
+		t.Error("SetField must fail for a value not assignable")
+	}
+	if err := xtypes.SetField(host, 1, nil); err == nil {
+		t.Error("SetField must fail for nil as a struct")
+	}
+	if err := xtypes.SetField(host, 3, nil); err == nil {
+		t.Error("SetField must fail out of range")
+	}
+	if err := xtypes.SetField(*host, 0, nil); err == nil {
+		t.Error("SetField must fail for a non-pointer")
+	}
+
+	pkg, err := makePkg(accessorSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(nil, nil, nil)
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("Inner").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	v := reflect.New(rt).Interface()
+	if err := xtypes.SetField(v, 0, []int{1, 2}); err != nil {
+		t.Fatalf("SetField c: %v", err)
+	}
+	c, _ := xtypes.Field(v, 0)
+	if err := xtypes.SetIndex(c, 1, 3); err != nil || c.([]int)[1] != 3 {
+		t.Errorf("SetIndex: %v %v", c, err)
+	}
+	if err := xtypes.SetIndex(c, 2, 3); err == nil {
+		t.Error("SetIndex must fail out of range")
+	}
+	if err := xtypes.SetIndex(c, 0, "s"); err == nil {
+		t.Error("SetIndex must fail for a value not assignable")
+	}
+	if err := xtypes.SetIndex([2]int{}, 0, 1); err == nil {
+		t.Error("SetIndex must fail for an unaddressable array")
+	}
+	a := &[2]int{}
+	if err := xtypes.SetIndex(a, 1, 1); err != nil || a[1] != 1 {
+		t.Errorf("SetIndex array pointer: %v %v", a, err)
+	}
+	if err := xtypes.SetField(v, 1, map[string]int{}); err != nil {
+		t.Fatalf("SetField m: %v", err)
+	}
+	m, _ := xtypes.Field(v, 1)
+	if err := xtypes.SetMapIndex(m, "a", 1); err != nil || m.(map[string]int)["a"] != 1 {
+		t.Errorf("SetMapIndex: %v %v", m, err)
+	}
+	if err := xtypes.SetMapIndex(m, 1, 1); err == nil {
+		t.Error("SetMapIndex must fail for a bad key type")
+	}
+	if err := xtypes.SetMapIndex(map[string]int(nil), "a", 1); err == nil {
+		t.Error("SetMapIndex must fail for a nil map")
+	}
+}
+
+func TestCallMethod(t *testing.T) {
+	hostPkgPath := reflect.TypeOf(hostT{}).PkgPath()
+	host := &hostT{x: 1}
+	if r, err := xtypes.CallMethod(host, hostPkgPath, "Sum", 2, 3); err != nil || r[0] != 6 || host.x != 6 {
+		t.Errorf("CallMethod Sum: %v %v", r, err)
+	}
+	if r, err := xtypes.CallMethod(host, hostPkgPath, "Sum"); err != nil || r[0] != 6 {
+		t.Errorf("CallMethod Sum without variadic arguments: %v %v", r, err)
+	}
+	if _, err := xtypes.CallMethod(*host, hostPkgPath, "Sum", 1); err == nil || host.x != 6 {
+		t.Errorf("CallMethod must fail for a pointer method of a non-pointer: %v", err)
+	}
+	if r, err := xtypes.CallMethod(host, hostPkgPath, "get"); err != nil || r[0] != 6 {
+		t.Errorf("CallMethod get: %v %v", r, err)
+	}
+	if _, err := xtypes.CallMethod(host, "other", "get"); err == nil {
+		t.Error("CallMethod must fail for an unexported method of another package")
+	}
+	if _, err := xtypes.CallMethod(host, hostPkgPath, "Sum", "s"); err == nil {
+		t.Error("CallMethod must fail for a bad argument")
+	}
+	if _, err := xtypes.CallMethod(host, hostPkgPath, "get", 1); err == nil {
+		t.Error("CallMethod must fail for a wrong number of arguments")
+	}
+	if _, err := xtypes.CallMethod(nil, hostPkgPath, "get"); err == nil {
+		t.Error("CallMethod must fail for nil")
+	}
+
+	pkg, err := makePkg(methodExprSrc)
+	if err != nil {
+		t.Fatalf("makePkg error %s", err)
+	}
+	ctx := xtypes.NewContext(func(mtyp reflect.Type, method *types.Func) func(args []reflect.Value) []reflect.Value {
+		if method.Name() == "get" {
+			return func(args []reflect.Value) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(int(args[0].Field(0).Int()) + 1)}
+			}
+		}
+		return func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(args[1].Len())}
+		}
+	}, nil, nil)
+	rt, err := xtypes.ToType(pkg.Scope().Lookup("T").Type(), ctx)
+	if err != nil {
+		t.Fatalf("ToType error %v", err)
+	}
+	v := reflect.New(rt).Elem().Interface()
+	if r, err := xtypes.CallMethod(v, "main", "get"); err != nil || r[0] != 1 {
+		t.Errorf("CallMethod get: %v %v", r, err)
+	}
+	if r, err := xtypes.CallMethod(reflect.New(rt).Interface(), "main", "Sum", 1, 2); err != nil || r[0] != 2 {
+		t.Errorf("CallMethod Sum: %v %v", r, err)
+	}
+	if _, err := xtypes.CallMethod(v, "main", "Sum", 1, 2); err == nil {
+		t.Error("CallMethod must fail for a pointer method of a non-pointer")
+	}
+	if _, err := xtypes.CallMethod(v, "main", "Len"); err == nil {
+		t.Error("CallMethod must fail for a missing method")
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	var host hostOuter
+	if _, err := xtypes.Field(&host, 3); !errors.Is(err, xtypes.ErrFieldIndex) {
+		t.Errorf("Field out of range: %v", err)
+	}
+	if _, err := xtypes.Field(1, 0); !errors.Is(err, xtypes.ErrNotStruct) {
+		t.Errorf("Field of non-struct: %v", err)
+	}
+	if _, err := xtypes.FieldAddr((*hostOuter)(nil), 0); !errors.Is(err, xtypes.ErrNilPointer) {
+		t.Errorf("FieldAddr of nil: %v", err)
+	}
+	if _, err := xtypes.FieldAddr(host, 0); err == nil {
+		t.Error("FieldAddr must fail for a struct value")
+	}
+	var i interface{} = &host
+	if p, err := xtypes.FieldAddr(&i, 1); err != nil || p != &host.b {
+		t.Errorf("FieldAddr through an interface: %v %v", p, err)
+	}
+	if _, err := xtypes.FieldByIndex(&host, []int{0, 0}); !errors.Is(err, xtypes.ErrNilPointer) {
+		t.Errorf("FieldByIndex through a nil embedded pointer: %v", err)
+	}
+	p, err := xtypes.FieldAddrByIndex(&host, []int{0, 0}, true)
+	if err != nil || host.hostInner == nil || p != &host.hostInner.c {
+		t.Errorf("FieldAddrByIndex with alloc: %v %v", p, err)
+	}
+	if c, err := xtypes.FieldByIndex(host, []int{1, 0}); err != nil || c.([]int) != nil {
+		t.Errorf("FieldByIndex: %v %v", c, err)
+	}
+}
+
+func TestKeepMethods(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the build of testdata/keepmethods in short mode")
+	}
+	// The test binary calls reflect.Value.MethodByName, which keeps the
+	// method data on its own: run a program that does not.
+	gocmd := filepath.Join(runtime.GOROOT(), "bin", "go")
+	out, err := exec.Command(gocmd, "run", "./testdata/keepmethods").CombinedOutput()
+	if err != nil {
+		t.Fatalf("run keepmethods error %v\n%s", err, out)
+	}
+	if want := "Inc 2\nGet 3\n"; string(out) != want {
+		t.Errorf("keepmethods output:\n%s\nwant:\n%s", out, want)
+	}
+}